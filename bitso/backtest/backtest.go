@@ -0,0 +1,356 @@
+// Package backtest replays recorded market data through a simulated
+// exchange to evaluate trading strategies. Runs are deterministic: the same
+// data, configuration and strategy always produce the same report.
+package backtest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/xiam/bitso-go/bitso"
+)
+
+// fillsPageSize is the page size used to collect fills from the simulated
+// exchange.
+const fillsPageSize = 100
+
+// Config describes the simulated account.
+type Config struct {
+	// Books that can be traded, fees are taken from their flat rate.
+	Books []bitso.ExchangeOrderBook
+	// Balances at the start of the run.
+	Balances map[bitso.Currency]bitso.Monetary
+	// Quote is the currency equity is measured in. Other currencies are
+	// valued at the last price of their book against Quote.
+	Quote bitso.Currency
+	// Latency is how long it takes the exchange to act on the orders and
+	// cancellations of the strategy. They are acted on before the first
+	// event received after that, so the ones due after the last event are
+	// never executed.
+	Latency time.Duration
+}
+
+// A Strategy is called after every event and returns the orders it wants to
+// place, if any.
+type Strategy func(v *View, event Event) []bitso.OrderPlacement
+
+type action struct {
+	at     time.Time
+	place  *bitso.OrderPlacement
+	cancel string
+}
+
+// position tracks the cost of the major currency of a book, to compute the
+// PnL of sells.
+type position struct {
+	amount decimal.Decimal
+	cost   decimal.Decimal
+	priced bool
+}
+
+type backtest struct {
+	cfg   Config
+	paper *bitso.PaperClient
+	now   time.Time
+	// clock is the time seen by paper, actions run at their own time.
+	clock time.Time
+
+	// actions wait for their time, in the order they were asked for.
+	actions []action
+
+	// marks holds the last price of each currency in cfg.Quote.
+	marks     map[bitso.Currency]decimal.Decimal
+	positions map[bitso.Book]*position
+
+	// marker is the ID of the last fill collected.
+	marker string
+	oids   []string
+	peak   decimal.Decimal
+
+	report *Report
+}
+
+// Run replays the events of src through a PaperClient set up with cfg,
+// passing each of them to strategy, and reports the results. Orders take at
+// most the liquidity the recorded books show: what they took is not taken
+// again when later snapshots still list it.
+func Run(src Source, cfg Config, strategy Strategy) (*Report, error) {
+	if cfg.Quote == "" {
+		return nil, errors.New("missing quote currency")
+	}
+
+	bt := &backtest{
+		cfg:       cfg,
+		paper:     bitso.NewPaperClient(cfg.Books),
+		marks:     map[bitso.Currency]decimal.Decimal{},
+		positions: map[bitso.Book]*position{},
+		report:    &Report{},
+	}
+	bt.paper.SetClock(func() time.Time {
+		return bt.clock
+	})
+	for currency, amount := range cfg.Balances {
+		if err := bt.paper.SetBalance(currency, amount); err != nil {
+			return nil, err
+		}
+	}
+	for _, config := range cfg.Books {
+		amount, _ := cfg.Balances[config.Book.Major()].Decimal()
+		bt.positions[config.Book] = &position{amount: amount}
+	}
+
+	view := &View{bt: bt}
+	for {
+		event, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if event.Time.After(bt.now) {
+			bt.now, bt.clock = event.Time, event.Time
+		}
+		if bt.report.Start.IsZero() {
+			bt.report.Start = bt.now
+		}
+
+		bt.runActions()
+		bt.paper.Feed(event.Message)
+		bt.updateMarks(event.Message)
+		if err := bt.collectFills(); err != nil {
+			return nil, err
+		}
+		bt.sample()
+
+		for _, placement := range strategy(view, event) {
+			bt.schedule(action{place: &placement})
+		}
+		bt.runActions()
+
+		if err := bt.collectFills(); err != nil {
+			return nil, err
+		}
+		bt.sample()
+	}
+
+	bt.report.End = bt.now
+	orders, err := bt.paper.LookupOrders(bt.oids)
+	if err != nil {
+		return nil, err
+	}
+	bt.report.Orders = orders
+	return bt.report, nil
+}
+
+func (bt *backtest) schedule(a action) {
+	a.at = bt.now.Add(bt.cfg.Latency)
+	bt.actions = append(bt.actions, a)
+}
+
+// runActions executes the actions that are due.
+func (bt *backtest) runActions() {
+	ctx := context.Background()
+	defer func() {
+		bt.clock = bt.now
+	}()
+
+	for len(bt.actions) > 0 && !bt.actions[0].at.After(bt.now) {
+		a := bt.actions[0]
+		bt.actions = bt.actions[1:]
+		bt.clock = a.at
+
+		if a.place == nil {
+			_, _ = bt.paper.CancelOrderContext(ctx, a.cancel)
+			continue
+		}
+
+		oid, err := bt.paper.PlaceOrderContext(ctx, a.place)
+		if err != nil {
+			bt.report.Rejected = append(bt.report.Rejected, Rejection{
+				Time:  a.at,
+				Order: *a.place,
+				Err:   err,
+			})
+			continue
+		}
+		bt.oids = append(bt.oids, oid)
+	}
+}
+
+func (bt *backtest) updateMarks(msg interface{}) {
+	switch m := msg.(type) {
+	case bitso.WebSocketTrade:
+		for _, trade := range m.Payload {
+			if price, err := trade.Price.Decimal(); err == nil {
+				bt.mark(m.Book, price)
+			}
+		}
+	case bitso.WebSocketOrder:
+		// Until there are trades, books are valued at their mid price.
+		if _, ok := bt.marks[m.Book.Major()]; ok || len(m.Payload.Bids) == 0 || len(m.Payload.Asks) == 0 {
+			return
+		}
+		book := m.OrderBook()
+		bid, err := book.Bids[0].Price.Decimal()
+		if err != nil {
+			return
+		}
+		ask, err := book.Asks[0].Price.Decimal()
+		if err != nil {
+			return
+		}
+		bt.mark(m.Book, bid.Add(ask).Div(decimal.NewFromInt(2)))
+	}
+}
+
+func (bt *backtest) mark(book bitso.Book, price decimal.Decimal) {
+	if book.Minor() != bt.cfg.Quote {
+		return
+	}
+	bt.marks[book.Major()] = price
+
+	if pos, ok := bt.positions[book]; ok && !pos.priced {
+		pos.cost = pos.amount.Mul(price)
+		pos.priced = true
+	}
+}
+
+// collectFills adds the trades made since the last call to the report.
+func (bt *backtest) collectFills() error {
+	for {
+		params := url.Values{
+			"sort":  {"asc"},
+			"limit": {strconv.Itoa(fillsPageSize)},
+		}
+		if bt.marker != "" {
+			params.Set("marker", bt.marker)
+		}
+
+		trades, err := bt.paper.MyTrades(params)
+		if err != nil {
+			return err
+		}
+		for _, trade := range trades {
+			bt.report.Fills = append(bt.report.Fills, bt.fill(trade))
+			bt.marker = strconv.FormatUint(trade.TID.Uint64(), 10)
+		}
+		if len(trades) < fillsPageSize {
+			return nil
+		}
+	}
+}
+
+// fill computes the PnL of a trade using the average cost of the position.
+func (bt *backtest) fill(trade bitso.UserTrade) Fill {
+	amount, _ := trade.Major.Decimal()
+	value, _ := trade.Minor.Decimal()
+	fee, _ := trade.FeesAmount.Decimal()
+	price, _ := trade.Price.Decimal()
+	amount, value = amount.Abs(), value.Abs()
+
+	pos, ok := bt.positions[trade.Book]
+	if !ok {
+		pos = &position{}
+		bt.positions[trade.Book] = pos
+	}
+	if !pos.priced {
+		pos.cost = pos.amount.Mul(price)
+		pos.priced = true
+	}
+
+	pnl := decimal.Zero
+	if trade.Side == bitso.OrderSideBuy {
+		pos.amount = pos.amount.Add(amount).Sub(fee)
+		pos.cost = pos.cost.Add(value)
+	} else {
+		average := price
+		if pos.amount.IsPositive() {
+			average = pos.cost.Div(pos.amount)
+		}
+		pnl = value.Sub(fee).Sub(average.Mul(amount))
+
+		pos.amount = pos.amount.Sub(amount)
+		pos.cost = pos.cost.Sub(average.Mul(amount))
+		if !pos.amount.IsPositive() {
+			pos.amount, pos.cost = decimal.Zero, decimal.Zero
+		}
+	}
+
+	return Fill{
+		UserTrade: trade,
+		PnL:       bitso.Monetary(pnl.Round(8).String()),
+	}
+}
+
+// equity returns the value of the account in the quote currency, it's false
+// if a currency held has no price yet.
+func (bt *backtest) equity() (decimal.Decimal, bool) {
+	balances, err := bt.paper.Balances(nil)
+	if err != nil {
+		return decimal.Zero, false
+	}
+
+	total := decimal.Zero
+	for _, balance := range balances {
+		amount, err := balance.Total.Decimal()
+		if err != nil || amount.IsZero() {
+			continue
+		}
+		if balance.Currency == bt.cfg.Quote {
+			total = total.Add(amount)
+			continue
+		}
+		price, ok := bt.marks[balance.Currency]
+		if !ok {
+			return decimal.Zero, false
+		}
+		total = total.Add(amount.Mul(price))
+	}
+	return total, true
+}
+
+// sample adds the current equity to the report.
+func (bt *backtest) sample() {
+	equity, ok := bt.equity()
+	if !ok {
+		return
+	}
+
+	r := bt.report
+	if len(r.Equity) == 0 {
+		r.InitialEquity = monetary(equity)
+	}
+	r.FinalEquity = monetary(equity)
+
+	if equity.GreaterThan(bt.peak) {
+		bt.peak = equity
+	}
+	drawdown := bt.peak.Sub(equity)
+
+	if max, _ := r.MaxDrawdown.Decimal(); drawdown.GreaterThan(max) {
+		r.MaxDrawdown = monetary(drawdown)
+		r.MaxDrawdownPercent = monetary(drawdown.Mul(decimal.NewFromInt(100)).DivRound(bt.peak, 4))
+	}
+
+	point := EquityPoint{
+		Time:     bt.now,
+		Equity:   monetary(equity),
+		Drawdown: monetary(drawdown),
+	}
+	if n := len(r.Equity); n > 0 && r.Equity[n-1].Time.Equal(bt.now) {
+		r.Equity[n-1] = point
+		return
+	}
+	r.Equity = append(r.Equity, point)
+}
+
+func monetary(d decimal.Decimal) bitso.Monetary {
+	return bitso.Monetary(d.String())
+}
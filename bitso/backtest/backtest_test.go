@@ -0,0 +1,216 @@
+package backtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xiam/bitso-go/bitso"
+	"github.com/xiam/bitso-go/bitso/recorder"
+)
+
+var (
+	btcMXN = *bitso.NewBook(bitso.BTC, bitso.MXN)
+	start  = time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+)
+
+func testConfig() Config {
+	return Config{
+		Books: []bitso.ExchangeOrderBook{{
+			Book: btcMXN,
+			Fees: bitso.BookFees{
+				FlatRate: bitso.BookFlatRate{Maker: "0", Taker: "1"},
+			},
+		}},
+		Balances: map[bitso.Currency]bitso.Monetary{
+			bitso.MXN: "1000",
+		},
+		Quote: bitso.MXN,
+	}
+}
+
+func snapshot(at time.Duration, bid, ask bitso.Monetary) Event {
+	msg := bitso.WebSocketOrder{Book: btcMXN}
+	msg.Payload.Bids = []bitso.StreamOrder{{OrderID: "b", Side: bitso.OrderSideBuy, Price: bid, Amount: "10"}}
+	msg.Payload.Asks = []bitso.StreamOrder{{OrderID: "a", Side: bitso.OrderSideSell, Price: ask, Amount: "10"}}
+	return Event{Time: start.Add(at), Message: msg}
+}
+
+// trade returns a trade that hit the asks.
+func trade(at time.Duration, price bitso.Monetary) Event {
+	return Event{
+		Time: start.Add(at),
+		Message: bitso.WebSocketTrade{
+			Book:    btcMXN,
+			Payload: []bitso.StreamTrade{{MakerSide: bitso.OrderSideSell, Price: price, Amount: "10"}},
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	src := Events(
+		snapshot(0, "99", "100"),
+		trade(time.Second, "90"),
+		trade(2*time.Second, "110"),
+	)
+
+	calls := 0
+	report, err := Run(src, testConfig(), func(v *View, event Event) []bitso.OrderPlacement {
+		calls++
+		switch calls {
+		case 1:
+			return []bitso.OrderPlacement{{Book: btcMXN, Side: bitso.OrderSideBuy, Type: bitso.OrderTypeMarket, Major: "5"}}
+		case 2:
+			balance := v.Balance(bitso.BTC)
+			return []bitso.OrderPlacement{{Book: btcMXN, Side: bitso.OrderSideSell, Type: bitso.OrderTypeLimit, Price: "110", Major: balance.Available}}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, start, report.Start)
+	assert.Equal(t, start.Add(2*time.Second), report.End)
+
+	require.Len(t, report.Fills, 2)
+	buy, sell := report.Fills[0], report.Fills[1]
+	assert.Equal(t, bitso.Monetary("100"), buy.Price)
+	assert.Equal(t, bitso.Monetary("0.05"), buy.FeesAmount)
+	assert.Equal(t, bitso.Monetary("0"), buy.PnL)
+	assert.Equal(t, bitso.Monetary("110"), sell.Price)
+	// 4.95 * 110 - 500
+	assert.Equal(t, bitso.Monetary("44.5"), sell.PnL)
+	assert.Equal(t, bitso.Monetary("44.5"), report.PnL())
+
+	assert.Equal(t, []EquityPoint{
+		{Time: start, Equity: "992.525", Drawdown: "7.475"},
+		{Time: start.Add(time.Second), Equity: "945.5", Drawdown: "54.5"},
+		{Time: start.Add(2 * time.Second), Equity: "1044.5", Drawdown: "0"},
+	}, report.Equity)
+	assert.Equal(t, bitso.Monetary("1000"), report.InitialEquity)
+	assert.Equal(t, bitso.Monetary("1044.5"), report.FinalEquity)
+	assert.Equal(t, bitso.Monetary("54.5"), report.MaxDrawdown)
+	assert.Equal(t, bitso.Monetary("5.45"), report.MaxDrawdownPercent)
+
+	require.Len(t, report.Orders, 2)
+	assert.Equal(t, bitso.OrderStatusCompleted, report.Orders[1].Status)
+	assert.Empty(t, report.Rejected)
+}
+
+func TestRun_Latency(t *testing.T) {
+	cfg := testConfig()
+	cfg.Latency = time.Second
+
+	src := Events(
+		snapshot(0, "99", "100"),
+		trade(500*time.Millisecond, "100"),
+		snapshot(2*time.Second, "109", "110"),
+	)
+
+	calls := 0
+	report, err := Run(src, cfg, func(v *View, event Event) []bitso.OrderPlacement {
+		calls++
+		if calls > 1 {
+			return nil
+		}
+		return []bitso.OrderPlacement{
+			{Book: btcMXN, Side: bitso.OrderSideBuy, Type: bitso.OrderTypeMarket, Major: "1"},
+			{Book: btcMXN, Side: bitso.OrderSideBuy, Type: bitso.OrderTypeMarket, Minor: "5000"},
+		}
+	})
+	require.NoError(t, err)
+
+	require.Len(t, report.Fills, 1)
+	// The order reached the exchange before the second snapshot.
+	assert.Equal(t, bitso.Time(start.Add(time.Second)), report.Fills[0].CreatedAt)
+	assert.Equal(t, bitso.Monetary("100"), report.Fills[0].Price)
+
+	require.Len(t, report.Rejected, 1)
+	assert.ErrorIs(t, report.Rejected[0].Err, bitso.ErrInsufficientFunds)
+	assert.Equal(t, start.Add(time.Second), report.Rejected[0].Time)
+}
+
+func TestRun_Cancel(t *testing.T) {
+	src := Events(
+		snapshot(0, "99", "100"),
+		snapshot(time.Second, "99", "100"),
+		trade(2*time.Second, "95"),
+	)
+
+	report, err := Run(src, testConfig(), func(v *View, event Event) []bitso.OrderPlacement {
+		orders := v.OpenOrders()
+		if len(orders) > 0 {
+			v.Cancel(orders[0].OID)
+			return nil
+		}
+		if v.Time().Equal(start) {
+			return []bitso.OrderPlacement{{Book: btcMXN, Side: bitso.OrderSideBuy, Type: bitso.OrderTypeLimit, Price: "95", Major: "1"}}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Empty(t, report.Fills)
+	require.Len(t, report.Orders, 1)
+	assert.Equal(t, bitso.OrderStatusCancelled, report.Orders[0].Status)
+}
+
+func TestRun_Recorded(t *testing.T) {
+	dir := t.TempDir()
+	w, err := recorder.NewWriter(dir)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(start, []byte(`{"type": "orders", "book": "btc_mxn", "payload": {"bids": [{"o": "b", "r": "99", "a": "10", "t": 0}], "asks": [{"o": "a", "r": "100", "a": "10", "t": 1}]}}`)))
+	require.NoError(t, w.Write(start.Add(time.Second), []byte(`{"type": "ka"}`)))
+	require.NoError(t, w.Close())
+
+	files, err := recorder.Files(dir, "bitso")
+	require.NoError(t, err)
+	r := recorder.NewReader(files...)
+	defer r.Close()
+
+	report, err := Run(r, testConfig(), func(v *View, event Event) []bitso.OrderPlacement {
+		if v.Time().Equal(start) {
+			return []bitso.OrderPlacement{{Book: btcMXN, Side: bitso.OrderSideBuy, Type: bitso.OrderTypeMarket, Major: "1"}}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, start.Add(time.Second), report.End)
+	require.Len(t, report.Fills, 1)
+	assert.Equal(t, bitso.Monetary("100"), report.Fills[0].Price)
+}
+
+func TestRun_RepeatedSnapshots(t *testing.T) {
+	cfg := testConfig()
+	cfg.Balances[bitso.MXN] = "10000"
+
+	// The ask of 10 stays listed while the order that took it is still
+	// crossed.
+	src := Events(
+		snapshot(0, "99", "100"),
+		snapshot(time.Second, "99", "100"),
+		snapshot(2*time.Second, "99", "100"),
+	)
+
+	report, err := Run(src, cfg, func(v *View, event Event) []bitso.OrderPlacement {
+		if v.Time().Equal(start) {
+			return []bitso.OrderPlacement{{Book: btcMXN, Side: bitso.OrderSideBuy, Type: bitso.OrderTypeLimit, Price: "100", Major: "20"}}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	volume := decimal.Zero
+	for _, fill := range report.Fills {
+		amount, err := fill.Major.Decimal()
+		require.NoError(t, err)
+		volume = volume.Add(amount)
+	}
+	assert.Equal(t, "10", volume.String())
+
+	require.Len(t, report.Orders, 1)
+	assert.Equal(t, bitso.OrderStatusPartialFill, report.Orders[0].Status)
+}
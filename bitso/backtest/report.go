@@ -0,0 +1,67 @@
+package backtest
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/xiam/bitso-go/bitso"
+)
+
+// A Report holds the results of a run. Amounts are in the quote currency.
+type Report struct {
+	Start time.Time
+	End   time.Time
+
+	InitialEquity bitso.Monetary
+	FinalEquity   bitso.Monetary
+
+	// Equity after every event, from the first time every currency held has
+	// a price.
+	Equity []EquityPoint
+
+	// Largest fall of equity from a previous peak.
+	MaxDrawdown        bitso.Monetary
+	MaxDrawdownPercent bitso.Monetary
+
+	Fills []Fill
+
+	// Final state of every order placed.
+	Orders []bitso.UserOrder
+
+	// Orders the exchange did not accept.
+	Rejected []Rejection
+}
+
+// EquityPoint is the value of the account at a point in time.
+type EquityPoint struct {
+	Time     time.Time
+	Equity   bitso.Monetary
+	Drawdown bitso.Monetary
+}
+
+// A Fill is a trade made by the strategy.
+type Fill struct {
+	bitso.UserTrade
+
+	// PnL realized by a sell, net of fees, against the average cost of the
+	// amount sold. It's zero for buys. Balances held at the start are valued
+	// at the first price seen.
+	PnL bitso.Monetary
+}
+
+// A Rejection is an order that could not be placed.
+type Rejection struct {
+	Time  time.Time
+	Order bitso.OrderPlacement
+	Err   error
+}
+
+// PnL returns the PnL realized by all fills.
+func (r *Report) PnL() bitso.Monetary {
+	total := decimal.Zero
+	for _, fill := range r.Fills {
+		pnl, _ := fill.PnL.Decimal()
+		total = total.Add(pnl)
+	}
+	return monetary(total)
+}
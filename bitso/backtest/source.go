@@ -0,0 +1,41 @@
+package backtest
+
+import (
+	"io"
+
+	"github.com/xiam/bitso-go/bitso/recorder"
+)
+
+// An Event is a market data message and the time it was received.
+type Event = recorder.Event
+
+// A Source yields events in the order they were received. Next returns
+// io.EOF after the last event. Recorded market data can be read with
+// recorder.Reader and recorder.FileReader.
+type Source interface {
+	Next() (Event, error)
+}
+
+var (
+	_ Source = (*recorder.Reader)(nil)
+	_ Source = (*recorder.FileReader)(nil)
+)
+
+type sliceSource struct {
+	events []Event
+}
+
+// Events returns a Source that yields the given events, which is useful to
+// test strategies with synthetic data.
+func Events(events ...Event) Source {
+	return &sliceSource{events: events}
+}
+
+func (s *sliceSource) Next() (Event, error) {
+	if len(s.events) == 0 {
+		return Event{}, io.EOF
+	}
+	event := s.events[0]
+	s.events = s.events[1:]
+	return event, nil
+}
@@ -0,0 +1,58 @@
+package backtest
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/xiam/bitso-go/bitso"
+)
+
+// A View gives a strategy access to the simulated account.
+type View struct {
+	bt *backtest
+}
+
+// Time returns the time of the event being processed.
+func (v *View) Time() time.Time {
+	return v.bt.now
+}
+
+// Balance returns the balance of a currency.
+func (v *View) Balance(currency bitso.Currency) bitso.Balance {
+	balances, _ := v.bt.paper.Balances(nil)
+	for _, balance := range balances {
+		if balance.Currency == currency {
+			return balance
+		}
+	}
+	return bitso.Balance{Currency: currency}
+}
+
+// OpenOrders returns the orders that are still open, newest first.
+func (v *View) OpenOrders() []bitso.UserOrder {
+	var orders []bitso.UserOrder
+	params := url.Values{"limit": {"100"}}
+	for {
+		page, err := v.bt.paper.MyOpenOrders(params)
+		if err != nil || len(page) == 0 {
+			return orders
+		}
+		orders = append(orders, page...)
+		params.Set("marker", page[len(page)-1].OID)
+	}
+}
+
+// Price returns the last price of a currency in the quote currency, if known.
+func (v *View) Price(currency bitso.Currency) (bitso.Monetary, bool) {
+	price, ok := v.bt.marks[currency]
+	if !ok {
+		return "", false
+	}
+	return monetary(price), true
+}
+
+// Cancel asks for an order to be cancelled. Like orders, cancellations take
+// effect after the configured latency.
+func (v *View) Cancel(oid string) {
+	v.bt.schedule(action{cancel: oid})
+}
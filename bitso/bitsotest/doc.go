@@ -0,0 +1,3 @@
+// Package bitsotest provides fakes of Bitso's services for testing code that
+// uses package bitso without network access.
+package bitsotest
@@ -0,0 +1,666 @@
+package bitsotest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/xiam/bitso-go/bitso"
+	"github.com/xiam/bitso-go/bitso/internal/matching"
+)
+
+// An Exchange is an in-process fake of Bitso's v3 REST API. It verifies the
+// signature and nonce of private requests, keeps the user's balances in
+// memory and matches their orders against the orders added with AddOrder.
+//
+// Only the endpoints used by bitso.Client are implemented.
+type Exchange struct {
+	// URL of the API, use it with bitso.Client.SetAPIBaseURL.
+	URL string
+
+	server *httptest.Server
+
+	key    string
+	secret string
+	nonce  int64
+
+	markets map[bitso.Book]*market
+	books   []bitso.Book
+
+	account *matching.Account
+	lastOID uint64
+	lastTID uint64
+
+	now func() time.Time
+
+	mu sync.Mutex
+}
+
+// NewExchange starts a fake exchange that accepts requests signed with the
+// given API key and secret. The caller should call Close when finished, to
+// shut it down.
+func NewExchange(key, secret string) *Exchange {
+	e := &Exchange{
+		key:     key,
+		secret:  secret,
+		markets: map[bitso.Book]*market{},
+		now:     time.Now,
+	}
+	e.account = &matching.Account{
+		Balances:   map[string]decimal.Decimal{},
+		RestOnBook: true,
+		NewOID: func() string {
+			e.lastOID++
+			return fmt.Sprintf("%016x", e.lastOID)
+		},
+		NewTID: func() uint64 {
+			e.lastTID++
+			return e.lastTID
+		},
+		OnFill: e.onFill,
+	}
+	e.server = httptest.NewServer(e.routes())
+	e.URL = e.server.URL + "/api"
+	return e
+}
+
+// Close shuts down the exchange.
+func (e *Exchange) Close() {
+	e.server.Close()
+}
+
+// Client returns a new bitso.Client configured to use the exchange.
+func (e *Exchange) Client() *bitso.Client {
+	client := bitso.NewClient()
+	client.SetAPIBaseURL(e.URL)
+	client.SetAuth(e.key, e.secret)
+	return client
+}
+
+// SetClock sets the function used to timestamp orders and trades, the
+// default is time.Now.
+func (e *Exchange) SetClock(now func() time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.now = now
+}
+
+// AddBook makes a book available for trading. Fees are taken from
+// config.Fees.FlatRate, as percentages.
+func (e *Exchange) AddBook(config bitso.ExchangeOrderBook) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.markets[config.Book]; !ok {
+		e.books = append(e.books, config.Book)
+	}
+	e.markets[config.Book] = newMarket(config)
+}
+
+// SetBalance sets the user's total balance of a currency.
+func (e *Exchange) SetBalance(currency bitso.Currency, total bitso.Monetary) error {
+	amount, err := total.Decimal()
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.account.Balances[currency.String()] = amount
+	return nil
+}
+
+// Balance returns the user's balance of a currency.
+func (e *Exchange) Balance(currency bitso.Currency) bitso.Balance {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.balance(currency)
+}
+
+// AddOrder places a limit order on behalf of another trader. It's matched
+// against the book, including the user's orders, and whatever is left rests
+// on the book. It returns the ID of the order.
+func (e *Exchange) AddOrder(book bitso.Book, side bitso.OrderSide, price, amount bitso.Monetary) (string, error) {
+	p, err := price.Decimal()
+	if err != nil {
+		return "", err
+	}
+	a, err := amount.Decimal()
+	if err != nil {
+		return "", err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	m, ok := e.markets[book]
+	if !ok {
+		return "", bitso.ErrUnknownBook
+	}
+	if !p.IsPositive() || !a.IsPositive() {
+		return "", fmt.Errorf("invalid order %v @ %v", amount, price)
+	}
+
+	if side != bitso.OrderSideBuy && side != bitso.OrderSideSell {
+		return "", fmt.Errorf("invalid side %v", side)
+	}
+
+	now := e.now()
+	o := &matching.Order{
+		ID:          e.account.NewOID(),
+		Book:        m.book,
+		Side:        matching.Side(side),
+		Type:        matching.Limit,
+		TimeInForce: matching.GoodTillCancelled,
+		Price:       p,
+		Amount:      a,
+		Unfilled:    a,
+		Status:      matching.Open,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	e.account.Take(o, now)
+	if o.Pending() {
+		m.book.Rest(o)
+	}
+	return o.ID, nil
+}
+
+func (e *Exchange) balance(currency bitso.Currency) bitso.Balance {
+	total := e.account.Balances[currency.String()]
+	locked := e.account.Locked(currency.String())
+	return bitso.Balance{
+		Currency:          currency,
+		Total:             monetary(total),
+		Locked:            monetary(locked),
+		Available:         monetary(total.Sub(locked)),
+		PendingDeposit:    "0",
+		PendingWithdrawal: "0",
+	}
+}
+
+// Error codes sent for the failures that bitso matches to its errors, plus
+// codeDuplicatedOriginID, which Bitso's documentation gives no code for.
+const (
+	codeInvalidSignature   = "0205"
+	codeInsufficientFunds  = "0379"
+	codeOrderNotFound      = "0404"
+	codeDuplicatedOriginID = "0405"
+)
+
+// apiError is sent to clients as a failed envelope.
+type apiError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (err *apiError) Error() string {
+	return err.message
+}
+
+func newAPIError(status int, code string, format string, args ...interface{}) *apiError {
+	return &apiError{status: status, code: code, message: fmt.Sprintf(format, args...)}
+}
+
+func invalidPayload(format string, args ...interface{}) *apiError {
+	return newAPIError(http.StatusBadRequest, "0304", format, args...)
+}
+
+type handlerFunc func(r *http.Request, body []byte) (interface{}, error)
+
+func (e *Exchange) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	handle := func(pattern string, private bool, h handlerFunc) {
+		method, path, _ := strings.Cut(pattern, " ")
+		mux.Handle(method+" /api/v3"+path, e.serve(private, h))
+	}
+
+	handle("GET /available_books", false, e.availableBooks)
+	handle("GET /ticker", false, e.ticker)
+	handle("GET /order_book", false, e.orderBook)
+	handle("GET /trades", false, e.trades)
+
+	handle("GET /balance", true, e.balanceList)
+	handle("GET /fees", true, e.fees)
+	handle("GET /ledger", true, e.ledgerList)
+	handle("GET /ledger/{operation}", true, e.ledgerList)
+	handle("GET /fundings/", true, e.emptyList)
+	handle("GET /withdrawals", true, e.emptyList)
+	handle("GET /user_trades", true, e.userTradeList)
+	handle("GET /order_trades/{oid}", true, e.orderTrades)
+	handle("GET /open_orders", true, e.openOrders)
+	handle("GET /orders", true, e.lookupOrders)
+	handle("GET /orders/{oids}", true, e.lookupOrders)
+	handle("POST /orders/", true, e.placeOrder)
+	handle("DELETE /orders", true, e.cancelOrders)
+	handle("DELETE /orders/all", true, e.cancelOrders)
+	handle("DELETE /orders/{oids}", true, e.cancelOrders)
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, newAPIError(http.StatusNotFound, "0101", "unknown endpoint %s %s", r.Method, r.URL.Path))
+	})
+
+	return mux
+}
+
+func (e *Exchange) serve(private bool, h handlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, invalidPayload("can not read body: %v", err))
+			return
+		}
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		if private {
+			if err := e.authenticate(r, body); err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+
+		payload, err := h(r, body)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		// Writing only fails if the client is gone.
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"payload": payload,
+		})
+	})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	apiErr, ok := err.(*apiError)
+	if !ok {
+		apiErr = newAPIError(http.StatusInternalServerError, "0101", "%v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"error": map[string]string{
+			"code":    apiErr.code,
+			"message": apiErr.message,
+		},
+	})
+}
+
+// authenticate verifies the "Bitso key:nonce:signature" authorization header
+// of a private request.
+func (e *Exchange) authenticate(r *http.Request, body []byte) error {
+	unauthorized := func(code, message string) error {
+		return newAPIError(http.StatusUnauthorized, code, "%s", message)
+	}
+
+	credentials, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bitso ")
+	if !ok {
+		return unauthorized("0201", "missing authorization header")
+	}
+	parts := strings.Split(credentials, ":")
+	if len(parts) != 3 {
+		return unauthorized("0201", "malformed authorization header")
+	}
+	key, rawNonce, signature := parts[0], parts[1], parts[2]
+
+	if key != e.key {
+		return unauthorized("0203", "invalid API key")
+	}
+
+	nonce, err := strconv.ParseInt(rawNonce, 10, 64)
+	if err != nil || nonce <= e.nonce {
+		return unauthorized("0201", "invalid nonce")
+	}
+
+	mac := hmac.New(sha256.New, []byte(e.secret))
+	mac.Write([]byte(rawNonce + r.Method + r.RequestURI + string(body)))
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return unauthorized(codeInvalidSignature, "invalid signature")
+	}
+
+	e.nonce = nonce
+	return nil
+}
+
+// paginate returns a page of items, which must be sorted oldest first,
+// following Bitso's marker, sort and limit parameters.
+func paginate[T any](items []T, id func(T) string, query url.Values) ([]T, error) {
+	page, err := matching.Page(items, id, query)
+	if err != nil {
+		return nil, rejected(err)
+	}
+	return page, nil
+}
+
+func (e *Exchange) market(query url.Values) (*market, error) {
+	z := query.Get("book")
+	if z == "" {
+		return nil, invalidPayload("missing book")
+	}
+	for book, m := range e.markets {
+		if book.String() == z {
+			return m, nil
+		}
+	}
+	return nil, newAPIError(http.StatusBadRequest, "0301", "unknown book %q", z)
+}
+
+func (e *Exchange) availableBooks(r *http.Request, body []byte) (interface{}, error) {
+	books := make([]bitso.ExchangeOrderBook, 0, len(e.books))
+	for _, book := range e.books {
+		books = append(books, e.markets[book].config)
+	}
+	return books, nil
+}
+
+func (e *Exchange) ticker(r *http.Request, body []byte) (interface{}, error) {
+	if r.URL.Query().Get("book") == "" {
+		tickers := make([]bitso.Ticker, 0, len(e.books))
+		for _, book := range e.books {
+			tickers = append(tickers, e.tickerOf(e.markets[book]))
+		}
+		return tickers, nil
+	}
+
+	m, err := e.market(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+	return e.tickerOf(m), nil
+}
+
+func (e *Exchange) tickerOf(m *market) bitso.Ticker {
+	now := e.now()
+	ticker := bitso.Ticker{
+		Book:      m.config.Book,
+		CreatedAt: bitso.Time(now),
+	}
+	if bids := m.book.Bids; len(bids) > 0 {
+		ticker.Bid = monetary(bids[0].Price)
+	}
+	if asks := m.book.Asks; len(asks) > 0 {
+		ticker.Ask = monetary(asks[0].Price)
+	}
+	if len(m.trades) > 0 {
+		ticker.Last = m.trades[len(m.trades)-1].Price
+	}
+
+	var high, low, volume, value decimal.Decimal
+	for _, trade := range m.trades {
+		if trade.CreatedAt.Time().Before(now.Add(-24 * time.Hour)) {
+			continue
+		}
+		price, _ := trade.Price.Decimal()
+		amount, _ := trade.Amount.Decimal()
+		if volume.IsZero() || price.GreaterThan(high) {
+			high = price
+		}
+		if volume.IsZero() || price.LessThan(low) {
+			low = price
+		}
+		volume = volume.Add(amount)
+		value = value.Add(price.Mul(amount))
+	}
+	ticker.High, ticker.Low, ticker.Volume = monetary(high), monetary(low), monetary(volume)
+	if volume.IsPositive() {
+		ticker.Vwap = monetary(matching.Div(value, volume))
+	}
+	return ticker
+}
+
+func (e *Exchange) orderBook(r *http.Request, body []byte) (interface{}, error) {
+	query := r.URL.Query()
+	m, err := e.market(query)
+	if err != nil {
+		return nil, err
+	}
+	aggregate := query.Get("aggregate") != "false"
+
+	levels := func(orders []*matching.Order) []bitso.Order {
+		res := []bitso.Order{}
+		for _, o := range orders {
+			if aggregate && len(res) > 0 && res[len(res)-1].Price == monetary(o.Price) {
+				last := &res[len(res)-1]
+				amount, _ := last.Amount.Decimal()
+				last.Amount = monetary(amount.Add(o.Unfilled))
+				continue
+			}
+			level := bitso.Order{
+				Book:   m.config.Book,
+				Price:  monetary(o.Price),
+				Amount: monetary(o.Unfilled),
+			}
+			if !aggregate {
+				level.OID = o.ID
+			}
+			res = append(res, level)
+		}
+		return res
+	}
+
+	return bitso.OrderBook{
+		Bids:      levels(m.book.Bids),
+		Asks:      levels(m.book.Asks),
+		UpdatedAt: bitso.Time(e.now()),
+		Sequence:  strconv.FormatUint(m.book.Sequence, 10),
+	}, nil
+}
+
+func (e *Exchange) trades(r *http.Request, body []byte) (interface{}, error) {
+	m, err := e.market(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+	return paginate(m.trades, func(trade bitso.Trade) string {
+		return strconv.FormatUint(trade.TID.Uint64(), 10)
+	}, r.URL.Query())
+}
+
+func (e *Exchange) balanceList(r *http.Request, body []byte) (interface{}, error) {
+	currencies := make([]bitso.Currency, 0, len(e.account.Balances))
+	for currency := range e.account.Balances {
+		currencies = append(currencies, bitso.ToCurrency(currency))
+	}
+	slices.SortFunc(currencies, func(a, b bitso.Currency) int {
+		return strings.Compare(a.String(), b.String())
+	})
+
+	balances := make([]bitso.Balance, 0, len(currencies))
+	for _, currency := range currencies {
+		balances = append(balances, e.balance(currency))
+	}
+	return map[string]interface{}{
+		"balances": balances,
+	}, nil
+}
+
+func (e *Exchange) fees(r *http.Request, body []byte) (interface{}, error) {
+	fees := bitso.CustomerFees{
+		Fees:           []bitso.Fee{},
+		WithdrawalFees: map[string]bitso.Monetary{},
+	}
+	for _, book := range e.books {
+		m := e.markets[book]
+		fees.Fees = append(fees.Fees, bitso.Fee{
+			Book:       book,
+			FeeDecimal: monetary(m.book.Taker),
+			FeePercent: monetary(m.book.Taker.Mul(decimal.NewFromInt(100))),
+		})
+	}
+	return fees, nil
+}
+
+func (e *Exchange) ledgerList(r *http.Request, body []byte) (interface{}, error) {
+	operations := map[string]bitso.Operation{
+		"fundings":    bitso.OperationFunding,
+		"withdrawals": bitso.OperationWithdrawal,
+		"trades":      bitso.OperationTrade,
+		"fees":        bitso.OperationFee,
+	}
+
+	entries := e.ledger()
+	if z := r.PathValue("operation"); z != "" {
+		op, ok := operations[z]
+		if !ok {
+			return nil, invalidPayload("unknown operation %q", z)
+		}
+		entries = slices.DeleteFunc(slices.Clone(entries), func(entry ledgerEntry) bool {
+			return entry.Operation != op
+		})
+	}
+
+	return paginate(entries, func(entry ledgerEntry) string {
+		return entry.EID
+	}, r.URL.Query())
+}
+
+func (e *Exchange) emptyList(r *http.Request, body []byte) (interface{}, error) {
+	return []interface{}{}, nil
+}
+
+func (e *Exchange) userTradeList(r *http.Request, body []byte) (interface{}, error) {
+	query := r.URL.Query()
+
+	trades := make([]bitso.UserTrade, 0, len(e.account.Trades))
+	for _, trade := range e.account.Trades {
+		trades = append(trades, e.userTrade(trade))
+	}
+	if query.Get("book") != "" {
+		m, err := e.market(query)
+		if err != nil {
+			return nil, err
+		}
+		trades = slices.DeleteFunc(slices.Clone(trades), func(trade bitso.UserTrade) bool {
+			return trade.Book != m.config.Book
+		})
+	}
+
+	return paginate(trades, func(trade bitso.UserTrade) string {
+		return strconv.FormatUint(trade.TID.Uint64(), 10)
+	}, query)
+}
+
+func (e *Exchange) orderTrades(r *http.Request, body []byte) (interface{}, error) {
+	oid := r.PathValue("oid")
+	if e.account.Lookup(oid) == nil {
+		return nil, newAPIError(http.StatusNotFound, codeOrderNotFound, "order %q not found", oid)
+	}
+
+	trades := []bitso.UserOrderTrade{}
+	for _, trade := range e.account.Trades {
+		if trade.Order.ID != oid {
+			continue
+		}
+		trades = append(trades, bitso.UserOrderTrade(e.userTrade(trade)))
+	}
+	return trades, nil
+}
+
+func (e *Exchange) openOrders(r *http.Request, body []byte) (interface{}, error) {
+	query := r.URL.Query()
+
+	var book *bitso.Book
+	if query.Get("book") != "" {
+		m, err := e.market(query)
+		if err != nil {
+			return nil, err
+		}
+		book = &m.config.Book
+	}
+
+	var open []bitso.UserOrder
+	for _, o := range e.account.Orders {
+		if o.IsOpen() && (book == nil || e.marketOf(o.Book).config.Book == *book) {
+			open = append(open, e.userOrder(o))
+		}
+	}
+	return paginate(open, func(o bitso.UserOrder) string {
+		return o.OID
+	}, query)
+}
+
+// selectOrders returns the user's orders named by the request, either by
+// their IDs in the path or by their origin IDs in the query.
+func (e *Exchange) selectOrders(r *http.Request) ([]*matching.Order, bool) {
+	if strings.HasSuffix(r.URL.Path, "/orders/all") {
+		return e.account.Orders, false
+	}
+
+	byOriginID := r.PathValue("oids") == ""
+	ids := r.PathValue("oids")
+	if byOriginID {
+		ids = r.URL.Query().Get("origin_ids")
+	}
+	return e.account.Select(strings.Split(ids, ","), byOriginID), byOriginID
+}
+
+func (e *Exchange) lookupOrders(r *http.Request, body []byte) (interface{}, error) {
+	orders, _ := e.selectOrders(r)
+
+	res := []bitso.UserOrder{}
+	for _, o := range orders {
+		res = append(res, e.userOrder(o))
+	}
+	return res, nil
+}
+
+func (e *Exchange) cancelOrders(r *http.Request, body []byte) (interface{}, error) {
+	orders, byOriginID := e.selectOrders(r)
+	return e.account.Cancel(orders, byOriginID, e.now()), nil
+}
+
+func (e *Exchange) placeOrder(r *http.Request, body []byte) (interface{}, error) {
+	var placement bitso.OrderPlacement
+	if err := json.Unmarshal(body, &placement); err != nil {
+		return nil, invalidPayload("invalid order: %v", err)
+	}
+
+	m, ok := e.markets[placement.Book]
+	if !ok {
+		return nil, newAPIError(http.StatusBadRequest, "0301", "unknown book %q", placement.Book)
+	}
+
+	o, err := e.account.Place(m.book, matching.Placement{
+		Side:        matching.Side(placement.Side),
+		Type:        matching.Type(placement.Type),
+		TimeInForce: matching.TimeInForce(placement.TimeInForce),
+		OriginID:    placement.OriginID,
+		Major:       string(placement.Major),
+		Minor:       string(placement.Minor),
+		Price:       string(placement.Price),
+		Stop:        string(placement.Stop),
+	}, e.now())
+	if err != nil {
+		return nil, rejected(err)
+	}
+
+	return map[string]string{
+		"oid": o.ID,
+	}, nil
+}
@@ -0,0 +1,274 @@
+package bitsotest
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xiam/bitso-go/bitso"
+)
+
+var btcMXN = *bitso.NewBook(bitso.BTC, bitso.MXN)
+
+func newExchange(t *testing.T) (*Exchange, *bitso.Client) {
+	t.Helper()
+
+	exchange := NewExchange("key", "secret")
+	t.Cleanup(exchange.Close)
+
+	exchange.AddBook(bitso.ExchangeOrderBook{
+		Book:          btcMXN,
+		MinimumAmount: "0.0001",
+		Fees: bitso.BookFees{
+			FlatRate: bitso.BookFlatRate{Maker: "0.5", Taker: "0.65"},
+		},
+	})
+	require.NoError(t, exchange.SetBalance(bitso.BTC, "1"))
+	require.NoError(t, exchange.SetBalance(bitso.MXN, "1000000"))
+
+	client := exchange.Client()
+	client.SetLogLevel(zerolog.Disabled)
+	return exchange, client
+}
+
+func limitOrder(side bitso.OrderSide, price, amount bitso.Monetary) *bitso.OrderPlacement {
+	return &bitso.OrderPlacement{
+		Book:  btcMXN,
+		Side:  side,
+		Type:  bitso.OrderTypeLimit,
+		Price: price,
+		Major: amount,
+	}
+}
+
+func TestExchange_PlaceOrderAndFill(t *testing.T) {
+	exchange, client := newExchange(t)
+
+	oid, err := client.PlaceOrder(limitOrder(bitso.OrderSideBuy, "500000", "0.5"))
+	require.NoError(t, err)
+
+	balance := exchange.Balance(bitso.MXN)
+	assert.Equal(t, bitso.Monetary("250000"), balance.Locked)
+	assert.Equal(t, bitso.Monetary("750000"), balance.Available)
+
+	order, err := client.LookupOrder(oid)
+	require.NoError(t, err)
+	assert.Equal(t, bitso.OrderStatusOpen, order.Status)
+	assert.Equal(t, bitso.TimeInForceGoodTillCancelled, order.TimeInForce)
+
+	// Someone else sells into our bid, we're the maker.
+	_, err = exchange.AddOrder(btcMXN, bitso.OrderSideSell, "499000", "0.2")
+	require.NoError(t, err)
+
+	order, err = client.LookupOrder(oid)
+	require.NoError(t, err)
+	assert.Equal(t, bitso.OrderStatusPartialFill, order.Status)
+	assert.Equal(t, bitso.Monetary("0.3"), order.UnfilledAmount)
+
+	trades, err := client.OrderTrades(oid, nil)
+	require.NoError(t, err)
+	require.Len(t, trades, 1)
+	assert.Equal(t, bitso.Monetary("500000"), trades[0].Price)
+	assert.Equal(t, bitso.Monetary("0.001"), trades[0].FeesAmount)
+	assert.Equal(t, bitso.Currency(bitso.BTC), trades[0].FeesCurrency)
+
+	// 1 + 0.2 - 0.2 * 0.005
+	assert.Equal(t, bitso.Monetary("1.199"), exchange.Balance(bitso.BTC).Total)
+	// 1000000 - 0.2 * 500000, and 0.3 * 500000 still locked.
+	balance = exchange.Balance(bitso.MXN)
+	assert.Equal(t, bitso.Monetary("900000"), balance.Total)
+	assert.Equal(t, bitso.Monetary("150000"), balance.Locked)
+
+	ledger, err := client.Ledger(nil)
+	require.NoError(t, err)
+	require.Len(t, ledger, 2)
+	assert.Equal(t, bitso.OperationFee, ledger[0].Operation)
+	assert.Equal(t, bitso.OperationTrade, ledger[1].Operation)
+
+	publicTrades, err := client.Trades(url.Values{"book": {"btc_mxn"}})
+	require.NoError(t, err)
+	require.Len(t, publicTrades, 1)
+	assert.Equal(t, bitso.OrderSideBuy, publicTrades[0].MakerSide)
+}
+
+func TestExchange_MarketOrder(t *testing.T) {
+	exchange, client := newExchange(t)
+
+	_, err := exchange.AddOrder(btcMXN, bitso.OrderSideSell, "500000", "0.1")
+	require.NoError(t, err)
+	_, err = exchange.AddOrder(btcMXN, bitso.OrderSideSell, "510000", "0.1")
+	require.NoError(t, err)
+
+	oid, err := client.PlaceOrder(&bitso.OrderPlacement{
+		Book:  btcMXN,
+		Side:  bitso.OrderSideBuy,
+		Type:  bitso.OrderTypeMarket,
+		Minor: "100000",
+	})
+	require.NoError(t, err)
+
+	order, err := client.LookupOrder(oid)
+	require.NoError(t, err)
+	assert.Equal(t, bitso.OrderStatusCompleted, order.Status)
+	// 0.1 @ 500000, and 50000 / 510000 of the second ask.
+	assert.Equal(t, bitso.Monetary("0.19803921"), order.OriginalAmount)
+
+	trades, err := client.MyTrades(nil)
+	require.NoError(t, err)
+	require.Len(t, trades, 2)
+	assert.Equal(t, bitso.Monetary("510000"), trades[0].Price)
+	assert.Equal(t, bitso.Monetary("500000"), trades[1].Price)
+
+	ticker, err := client.Ticker(&btcMXN)
+	require.NoError(t, err)
+	assert.Equal(t, bitso.Monetary("510000"), ticker.Last)
+	assert.Equal(t, bitso.Monetary("510000"), ticker.Ask)
+	assert.Equal(t, bitso.Monetary("500000"), ticker.Low)
+}
+
+func TestExchange_TimeInForce(t *testing.T) {
+	exchange, client := newExchange(t)
+
+	_, err := exchange.AddOrder(btcMXN, bitso.OrderSideSell, "500000", "0.1")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		tif    bitso.TimeInForce
+		amount bitso.Monetary
+		status bitso.OrderStatus
+	}{
+		{bitso.TimeInForcePostOnly, "0.1", bitso.OrderStatusCancelled},
+		{bitso.TimeInForceFillOrKill, "0.2", bitso.OrderStatusCancelled},
+		{bitso.TimeInForceImmediateOrCancel, "0.05", bitso.OrderStatusCompleted},
+		{bitso.TimeInForceImmediateOrCancel, "0.1", bitso.OrderStatusCancelled},
+	} {
+		placement := limitOrder(bitso.OrderSideBuy, "500000", tc.amount)
+		placement.TimeInForce = tc.tif
+
+		oid, err := client.PlaceOrder(placement)
+		require.NoError(t, err)
+
+		order, err := client.LookupOrder(oid)
+		require.NoError(t, err)
+		assert.Equal(t, tc.status, order.Status, "%v %v", tc.tif, tc.amount)
+	}
+
+	orders, err := client.MyOpenOrders(nil)
+	require.NoError(t, err)
+	assert.Empty(t, orders)
+}
+
+func TestExchange_OpenOrdersAndCancel(t *testing.T) {
+	_, client := newExchange(t)
+	ctx := context.Background()
+
+	for i, price := range []bitso.Monetary{"400000", "410000", "420000"} {
+		placement := limitOrder(bitso.OrderSideBuy, price, "0.1")
+		if i == 0 {
+			placement.OriginID = "first"
+		}
+		_, err := client.PlaceOrder(placement)
+		require.NoError(t, err)
+	}
+
+	_, err := client.PlaceOrder(&bitso.OrderPlacement{
+		Book:     btcMXN,
+		Side:     bitso.OrderSideSell,
+		Type:     bitso.OrderTypeLimit,
+		Price:    "600000",
+		Major:    "0.1",
+		OriginID: "first",
+	})
+	assert.Equal(t, codeDuplicatedOriginID, errorCode(t, err))
+
+	var count int
+	for _, err := range client.MyOpenOrdersSeq(ctx, url.Values{"limit": {"2"}}, time.Time{}) {
+		require.NoError(t, err)
+		count++
+	}
+	assert.Equal(t, 3, count)
+
+	book, err := client.OrderBook(url.Values{"book": {"btc_mxn"}})
+	require.NoError(t, err)
+	require.Len(t, book.Bids, 3)
+	assert.Equal(t, bitso.Monetary("420000"), book.Bids[0].Price)
+
+	cancelled, err := client.CancelOrdersByOriginID([]string{"first"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first"}, cancelled)
+
+	cancelled, err = client.CancelAllOrders()
+	require.NoError(t, err)
+	assert.Len(t, cancelled, 2)
+
+	orders, err := client.MyOpenOrders(nil)
+	require.NoError(t, err)
+	assert.Empty(t, orders)
+
+	_, err = client.OrderTrades("unknown", nil)
+	assert.ErrorIs(t, err, bitso.ErrOrderNotFound)
+}
+
+func TestExchange_Errors(t *testing.T) {
+	exchange, client := newExchange(t)
+
+	_, err := client.PlaceOrder(limitOrder(bitso.OrderSideBuy, "500000", "3"))
+	assert.ErrorIs(t, err, bitso.ErrInsufficientFunds)
+
+	_, err = client.PlaceOrder(limitOrder(bitso.OrderSideSell, "500000", "0.00001"))
+	assert.ErrorIs(t, err, bitso.ErrInvalidPayload)
+
+	unknown := limitOrder(bitso.OrderSideSell, "500000", "0.1")
+	unknown.Book = *bitso.NewBook(bitso.ETH, bitso.MXN)
+	_, err = client.PlaceOrder(unknown)
+	assert.ErrorIs(t, err, bitso.ErrUnknownBook)
+
+	impostor := bitso.NewClient()
+	impostor.SetAPIBaseURL(exchange.URL)
+	impostor.SetAuth("key", "not the secret")
+	_, err = impostor.Balances(nil)
+	assert.ErrorIs(t, err, bitso.ErrInvalidSignature)
+
+	// The impostor's nonces are newer than the ones from client.
+	_, err = client.Balances(nil)
+	assert.NoError(t, err)
+	exchange.mu.Lock()
+	exchange.nonce = 1 << 62
+	exchange.mu.Unlock()
+	_, err = client.Balances(nil)
+	assert.ErrorIs(t, err, bitso.ErrInvalidNonce)
+	assert.Equal(t, "0201", errorCode(t, err))
+}
+
+// errorCode returns the code of the API error err.
+func errorCode(t *testing.T, err error) string {
+	t.Helper()
+
+	var apiErr *bitso.Error
+	require.True(t, errors.As(err, &apiErr), "%v", err)
+	return apiErr.RawCode()
+}
+
+func TestExchange_PublicEndpoints(t *testing.T) {
+	_, client := newExchange(t)
+
+	books, err := client.AvailableBooks()
+	require.NoError(t, err)
+	require.Len(t, books, 1)
+	assert.Equal(t, btcMXN, books[0].Book)
+
+	tickers, err := client.Tickers()
+	require.NoError(t, err)
+	assert.Len(t, tickers, 1)
+
+	fees, err := client.Fees(nil)
+	require.NoError(t, err)
+	require.Len(t, fees.Fees, 1)
+	assert.Equal(t, bitso.Monetary("0.0065"), fees.Fees[0].FeeDecimal)
+}
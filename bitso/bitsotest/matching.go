@@ -0,0 +1,169 @@
+package bitsotest
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+	"github.com/xiam/bitso-go/bitso"
+	"github.com/xiam/bitso-go/bitso/internal/matching"
+)
+
+// market holds the state of a single book.
+type market struct {
+	config bitso.ExchangeOrderBook
+	book   *matching.Book
+
+	// Public trades, oldest first.
+	trades []bitso.Trade
+}
+
+func newMarket(config bitso.ExchangeOrderBook) *market {
+	book := matching.NewBook(
+		config.Book.Major().String(),
+		config.Book.Minor().String(),
+		string(config.Fees.FlatRate.Maker),
+		string(config.Fees.FlatRate.Taker),
+		matching.Limits{
+			MinAmount: string(config.MinimumAmount),
+			MaxAmount: string(config.MaximumAmount),
+			MinPrice:  string(config.MinimumPrice),
+			MaxPrice:  string(config.MaximumPrice),
+			MinValue:  string(config.MinimumValue),
+			MaxValue:  string(config.MaximumValue),
+		},
+	)
+	// The exchange knows its whole book.
+	book.Synced = true
+
+	return &market{config: config, book: book}
+}
+
+// onFill records the public trade of a match.
+func (e *Exchange) onFill(fill matching.Fill) {
+	m := e.marketOf(fill.Book)
+	m.trades = append(m.trades, bitso.Trade{
+		Book:      m.config.Book,
+		CreatedAt: bitso.Time(fill.CreatedAt),
+		Amount:    monetary(fill.Amount),
+		MakerSide: bitso.OrderSide(fill.MakerSide),
+		Price:     monetary(fill.Price),
+		TID:       bitso.TID(fill.TID),
+	})
+}
+
+func (e *Exchange) marketOf(book *matching.Book) *market {
+	for _, m := range e.markets {
+		if m.book == book {
+			return m
+		}
+	}
+	return nil
+}
+
+// rejected turns a rejection of the matching engine into an API error.
+func rejected(err error) error {
+	var rejection *matching.Rejection
+	if !errors.As(err, &rejection) {
+		return err
+	}
+
+	code := "0304"
+	switch rejection.Reason {
+	case matching.ReasonInsufficientFunds:
+		code = codeInsufficientFunds
+	case matching.ReasonDuplicatedOriginID:
+		code = codeDuplicatedOriginID
+	}
+	return newAPIError(http.StatusBadRequest, code, "%v", rejection)
+}
+
+func (e *Exchange) userOrder(o *matching.Order) bitso.UserOrder {
+	res := bitso.UserOrder{
+		Book:           e.marketOf(o.Book).config.Book,
+		OriginalAmount: monetary(o.Amount),
+		UnfilledAmount: monetary(o.Unfilled),
+		CreatedAt:      bitso.Time(o.CreatedAt),
+		UpdatedAt:      bitso.Time(o.UpdatedAt),
+		OID:            o.ID,
+		Side:           bitso.OrderSide(o.Side),
+		Status:         bitso.OrderStatus(o.Status),
+		Type:           bitso.OrderType(o.Type).String(),
+		TimeInForce:    bitso.TimeInForce(o.TimeInForce),
+		OriginID:       o.OriginID,
+	}
+	if o.Type == matching.Limit {
+		res.Price = monetary(o.Price)
+		res.OriginalValue = monetary(o.Price.Mul(o.Amount))
+	}
+	if o.ByMinor {
+		res.OriginalValue = monetary(o.Value)
+	}
+	return res
+}
+
+func (e *Exchange) userTrade(trade matching.Trade) bitso.UserTrade {
+	return bitso.UserTrade{
+		Book:         e.marketOf(trade.Order.Book).config.Book,
+		Major:        monetary(trade.Major),
+		CreatedAt:    bitso.Time(trade.CreatedAt),
+		Minor:        monetary(trade.Minor),
+		FeesAmount:   monetary(trade.Fee),
+		FeesCurrency: bitso.ToCurrency(trade.FeeCurrency),
+		Price:        monetary(trade.Price),
+		TID:          bitso.TID(trade.TID),
+		OID:          trade.Order.ID,
+		Side:         bitso.OrderSide(trade.Order.Side),
+	}
+}
+
+func monetary(d decimal.Decimal) bitso.Monetary {
+	return bitso.Monetary(d.String())
+}
+
+type balanceUpdate struct {
+	Currency bitso.Currency `json:"currency"`
+	Amount   bitso.Monetary `json:"amount"`
+}
+
+// ledgerEntry is encoded like bitso.Transaction.
+type ledgerEntry struct {
+	EID            string                 `json:"eid"`
+	Operation      bitso.Operation        `json:"operation"`
+	CreatedAt      bitso.Time             `json:"created_at"`
+	BalanceUpdates []balanceUpdate        `json:"balance_updates"`
+	Details        map[string]interface{} `json:"details"`
+}
+
+// ledger returns the entries of the user's trades and their fees, oldest
+// first.
+func (e *Exchange) ledger() []ledgerEntry {
+	var entries []ledgerEntry
+	record := func(op bitso.Operation, trade bitso.UserTrade, updates ...balanceUpdate) {
+		entries = append(entries, ledgerEntry{
+			EID:            strconv.Itoa(len(entries) + 1),
+			Operation:      op,
+			CreatedAt:      trade.CreatedAt,
+			BalanceUpdates: updates,
+			Details: map[string]interface{}{
+				"tid": trade.TID,
+				"oid": trade.OID,
+			},
+		})
+	}
+
+	for _, t := range e.account.Trades {
+		trade := e.userTrade(t)
+		record(bitso.OperationTrade, trade,
+			balanceUpdate{trade.Book.Major(), trade.Major},
+			balanceUpdate{trade.Book.Minor(), trade.Minor},
+		)
+		if t.Fee.IsPositive() {
+			record(bitso.OperationFee, trade,
+				balanceUpdate{trade.FeesCurrency, monetary(t.Fee.Neg())},
+			)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,299 @@
+package bitsotest
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+
+	"github.com/xiam/bitso-go/bitso"
+)
+
+// ErrNotMocked is returned by the methods of a Mock that have no function
+// set.
+var ErrNotMocked = errors.New("bitsotest: method not mocked")
+
+// A Call is a method call received by a Mock. Args holds the arguments of
+// the call, except for the context.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// Mock implements bitso.Exchange by calling the function set for each
+// method, methods without a function return ErrNotMocked. All calls are
+// recorded. A Mock is safe for concurrent use.
+type Mock struct {
+	AvailableBooksFunc func(ctx context.Context) ([]bitso.ExchangeOrderBook, error)
+	TickersFunc        func(ctx context.Context) ([]bitso.Ticker, error)
+	TickerFunc         func(ctx context.Context, book *bitso.Book) (*bitso.Ticker, error)
+	TradesFunc         func(ctx context.Context, params url.Values) ([]bitso.Trade, error)
+	OrderBookFunc      func(ctx context.Context, params url.Values) (*bitso.OrderBook, error)
+
+	BalancesFunc          func(ctx context.Context, params url.Values) ([]bitso.Balance, error)
+	FeesFunc              func(ctx context.Context, params url.Values) (*bitso.CustomerFees, error)
+	LedgerFunc            func(ctx context.Context, params url.Values) ([]bitso.Transaction, error)
+	LedgerByOperationFunc func(ctx context.Context, op bitso.Operation, params url.Values) ([]bitso.Transaction, error)
+	FundingsFunc          func(ctx context.Context, params url.Values) ([]bitso.Funding, error)
+	WithdrawalsFunc       func(ctx context.Context, params url.Values) ([]bitso.Withdrawal, error)
+	MyTradesFunc          func(ctx context.Context, params url.Values) ([]bitso.UserTrade, error)
+
+	PlaceOrderFunc             func(ctx context.Context, order *bitso.OrderPlacement) (string, error)
+	MyOpenOrdersFunc           func(ctx context.Context, params url.Values) ([]bitso.UserOrder, error)
+	OrderTradesFunc            func(ctx context.Context, oid string, params url.Values) ([]bitso.UserOrderTrade, error)
+	LookupOrderFunc            func(ctx context.Context, oid string) (*bitso.UserOrder, error)
+	LookupOrdersFunc           func(ctx context.Context, oids []string) ([]bitso.UserOrder, error)
+	LookupOrdersByOriginIDFunc func(ctx context.Context, originIDs []string) ([]bitso.UserOrder, error)
+	CancelOrderFunc            func(ctx context.Context, oid string) ([]string, error)
+	CancelOrdersFunc           func(ctx context.Context, oids []string) ([]string, error)
+	CancelOrdersByOriginIDFunc func(ctx context.Context, originIDs []string) ([]string, error)
+	CancelAllOrdersFunc        func(ctx context.Context) ([]string, error)
+	CancelBookOrdersFunc       func(ctx context.Context, book *bitso.Book) ([]string, error)
+
+	calls []Call
+	mu    sync.Mutex
+}
+
+var _ bitso.Exchange = (*Mock)(nil)
+
+// Calls returns the calls received so far, oldest first.
+func (m *Mock) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Call(nil), m.calls...)
+}
+
+// CallsTo returns the calls received so far by the given method, e.g.
+// "PlaceOrderContext".
+func (m *Mock) CallsTo(method string) []Call {
+	var res []Call
+	for _, call := range m.Calls() {
+		if call.Method == method {
+			res = append(res, call)
+		}
+	}
+	return res
+}
+
+// Reset forgets the recorded calls.
+func (m *Mock) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls = nil
+}
+
+func (m *Mock) record(method string, args ...interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls = append(m.calls, Call{Method: method, Args: args})
+}
+
+// AvailableBooksContext implements bitso.Exchange.
+func (m *Mock) AvailableBooksContext(ctx context.Context) ([]bitso.ExchangeOrderBook, error) {
+	m.record("AvailableBooksContext")
+	if m.AvailableBooksFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.AvailableBooksFunc(ctx)
+}
+
+// TickersContext implements bitso.Exchange.
+func (m *Mock) TickersContext(ctx context.Context) ([]bitso.Ticker, error) {
+	m.record("TickersContext")
+	if m.TickersFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.TickersFunc(ctx)
+}
+
+// TickerContext implements bitso.Exchange.
+func (m *Mock) TickerContext(ctx context.Context, book *bitso.Book) (*bitso.Ticker, error) {
+	m.record("TickerContext", book)
+	if m.TickerFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.TickerFunc(ctx, book)
+}
+
+// TradesContext implements bitso.Exchange.
+func (m *Mock) TradesContext(ctx context.Context, params url.Values) ([]bitso.Trade, error) {
+	m.record("TradesContext", params)
+	if m.TradesFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.TradesFunc(ctx, params)
+}
+
+// OrderBookContext implements bitso.Exchange.
+func (m *Mock) OrderBookContext(ctx context.Context, params url.Values) (*bitso.OrderBook, error) {
+	m.record("OrderBookContext", params)
+	if m.OrderBookFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.OrderBookFunc(ctx, params)
+}
+
+// BalancesContext implements bitso.Exchange.
+func (m *Mock) BalancesContext(ctx context.Context, params url.Values) ([]bitso.Balance, error) {
+	m.record("BalancesContext", params)
+	if m.BalancesFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.BalancesFunc(ctx, params)
+}
+
+// FeesContext implements bitso.Exchange.
+func (m *Mock) FeesContext(ctx context.Context, params url.Values) (*bitso.CustomerFees, error) {
+	m.record("FeesContext", params)
+	if m.FeesFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.FeesFunc(ctx, params)
+}
+
+// LedgerContext implements bitso.Exchange.
+func (m *Mock) LedgerContext(ctx context.Context, params url.Values) ([]bitso.Transaction, error) {
+	m.record("LedgerContext", params)
+	if m.LedgerFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.LedgerFunc(ctx, params)
+}
+
+// LedgerByOperationContext implements bitso.Exchange.
+func (m *Mock) LedgerByOperationContext(ctx context.Context, op bitso.Operation, params url.Values) ([]bitso.Transaction, error) {
+	m.record("LedgerByOperationContext", op, params)
+	if m.LedgerByOperationFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.LedgerByOperationFunc(ctx, op, params)
+}
+
+// FundingsContext implements bitso.Exchange.
+func (m *Mock) FundingsContext(ctx context.Context, params url.Values) ([]bitso.Funding, error) {
+	m.record("FundingsContext", params)
+	if m.FundingsFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.FundingsFunc(ctx, params)
+}
+
+// WithdrawalsContext implements bitso.Exchange.
+func (m *Mock) WithdrawalsContext(ctx context.Context, params url.Values) ([]bitso.Withdrawal, error) {
+	m.record("WithdrawalsContext", params)
+	if m.WithdrawalsFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.WithdrawalsFunc(ctx, params)
+}
+
+// MyTradesContext implements bitso.Exchange.
+func (m *Mock) MyTradesContext(ctx context.Context, params url.Values) ([]bitso.UserTrade, error) {
+	m.record("MyTradesContext", params)
+	if m.MyTradesFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.MyTradesFunc(ctx, params)
+}
+
+// PlaceOrderContext implements bitso.Exchange.
+func (m *Mock) PlaceOrderContext(ctx context.Context, order *bitso.OrderPlacement) (string, error) {
+	m.record("PlaceOrderContext", order)
+	if m.PlaceOrderFunc == nil {
+		return "", ErrNotMocked
+	}
+	return m.PlaceOrderFunc(ctx, order)
+}
+
+// MyOpenOrdersContext implements bitso.Exchange.
+func (m *Mock) MyOpenOrdersContext(ctx context.Context, params url.Values) ([]bitso.UserOrder, error) {
+	m.record("MyOpenOrdersContext", params)
+	if m.MyOpenOrdersFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.MyOpenOrdersFunc(ctx, params)
+}
+
+// OrderTradesContext implements bitso.Exchange.
+func (m *Mock) OrderTradesContext(ctx context.Context, oid string, params url.Values) ([]bitso.UserOrderTrade, error) {
+	m.record("OrderTradesContext", oid, params)
+	if m.OrderTradesFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.OrderTradesFunc(ctx, oid, params)
+}
+
+// LookupOrderContext implements bitso.Exchange.
+func (m *Mock) LookupOrderContext(ctx context.Context, oid string) (*bitso.UserOrder, error) {
+	m.record("LookupOrderContext", oid)
+	if m.LookupOrderFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.LookupOrderFunc(ctx, oid)
+}
+
+// LookupOrdersContext implements bitso.Exchange.
+func (m *Mock) LookupOrdersContext(ctx context.Context, oids []string) ([]bitso.UserOrder, error) {
+	m.record("LookupOrdersContext", oids)
+	if m.LookupOrdersFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.LookupOrdersFunc(ctx, oids)
+}
+
+// LookupOrdersByOriginIDContext implements bitso.Exchange.
+func (m *Mock) LookupOrdersByOriginIDContext(ctx context.Context, originIDs []string) ([]bitso.UserOrder, error) {
+	m.record("LookupOrdersByOriginIDContext", originIDs)
+	if m.LookupOrdersByOriginIDFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.LookupOrdersByOriginIDFunc(ctx, originIDs)
+}
+
+// CancelOrderContext implements bitso.Exchange.
+func (m *Mock) CancelOrderContext(ctx context.Context, oid string) ([]string, error) {
+	m.record("CancelOrderContext", oid)
+	if m.CancelOrderFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.CancelOrderFunc(ctx, oid)
+}
+
+// CancelOrdersContext implements bitso.Exchange.
+func (m *Mock) CancelOrdersContext(ctx context.Context, oids []string) ([]string, error) {
+	m.record("CancelOrdersContext", oids)
+	if m.CancelOrdersFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.CancelOrdersFunc(ctx, oids)
+}
+
+// CancelOrdersByOriginIDContext implements bitso.Exchange.
+func (m *Mock) CancelOrdersByOriginIDContext(ctx context.Context, originIDs []string) ([]string, error) {
+	m.record("CancelOrdersByOriginIDContext", originIDs)
+	if m.CancelOrdersByOriginIDFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.CancelOrdersByOriginIDFunc(ctx, originIDs)
+}
+
+// CancelAllOrdersContext implements bitso.Exchange.
+func (m *Mock) CancelAllOrdersContext(ctx context.Context) ([]string, error) {
+	m.record("CancelAllOrdersContext")
+	if m.CancelAllOrdersFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.CancelAllOrdersFunc(ctx)
+}
+
+// CancelBookOrdersContext implements bitso.Exchange.
+func (m *Mock) CancelBookOrdersContext(ctx context.Context, book *bitso.Book) ([]string, error) {
+	m.record("CancelBookOrdersContext", book)
+	if m.CancelBookOrdersFunc == nil {
+		return nil, ErrNotMocked
+	}
+	return m.CancelBookOrdersFunc(ctx, book)
+}
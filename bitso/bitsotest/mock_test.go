@@ -0,0 +1,39 @@
+package bitsotest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xiam/bitso-go/bitso"
+)
+
+func TestMock(t *testing.T) {
+	mock := &Mock{
+		PlaceOrderFunc: func(ctx context.Context, order *bitso.OrderPlacement) (string, error) {
+			return "oid-1", nil
+		},
+	}
+
+	var trading bitso.Trading = mock
+	ctx := context.Background()
+
+	placement := limitOrder(bitso.OrderSideBuy, "500000", "0.1")
+	oid, err := trading.PlaceOrderContext(ctx, placement)
+	require.NoError(t, err)
+	assert.Equal(t, "oid-1", oid)
+
+	_, err = trading.CancelOrderContext(ctx, oid)
+	assert.ErrorIs(t, err, ErrNotMocked)
+
+	assert.Equal(t, []Call{
+		{Method: "PlaceOrderContext", Args: []interface{}{placement}},
+		{Method: "CancelOrderContext", Args: []interface{}{"oid-1"}},
+	}, mock.Calls())
+	assert.Len(t, mock.CallsTo("CancelOrderContext"), 1)
+
+	mock.Reset()
+	assert.Empty(t, mock.Calls())
+}
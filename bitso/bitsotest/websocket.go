@@ -0,0 +1,296 @@
+package bitsotest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/xiam/bitso-go/bitso"
+)
+
+// A WebSocketServer is a local websocket server that speaks Bitso's
+// subscription protocol. Use its URL with bitso.WithWebSocketURL.
+type WebSocketServer struct {
+	// URL of the server, in the form ws://127.0.0.1:port.
+	URL string
+
+	server   *httptest.Server
+	upgrader websocket.Upgrader
+
+	conns  []*wsConn
+	reject bool
+
+	// changed is closed and replaced whenever connections or subscriptions
+	// change.
+	changed chan struct{}
+
+	mu sync.Mutex
+}
+
+type wsConn struct {
+	conn          *websocket.Conn
+	subscriptions []bitso.WebSocketMessage
+
+	writeMu sync.Mutex
+}
+
+func (c *wsConn) subscribed(channel string, book bitso.Book) bool {
+	return slices.ContainsFunc(c.subscriptions, func(m bitso.WebSocketMessage) bool {
+		return m.Type == channel && m.Book != nil && *m.Book == book
+	})
+}
+
+func (c *wsConn) write(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// NewWebSocketServer starts and returns a new WebSocketServer. The caller
+// should call Close when finished, to shut it down.
+func NewWebSocketServer() *WebSocketServer {
+	s := &WebSocketServer{
+		changed: make(chan struct{}),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = "ws" + strings.TrimPrefix(s.server.URL, "http")
+	return s
+}
+
+// Close disconnects all clients and shuts down the server.
+func (s *WebSocketServer) Close() {
+	s.Disconnect()
+	s.server.Close()
+}
+
+// Reject makes the server refuse new connections with a 503 status while
+// reject is true, to simulate an outage.
+func (s *WebSocketServer) Reject(reject bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reject = reject
+}
+
+// Disconnect abruptly closes all client connections. Clients are free to
+// connect again.
+func (s *WebSocketServer) Disconnect() {
+	s.mu.Lock()
+	conns := s.conns
+	s.conns = nil
+	s.notifyLocked()
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.conn.Close()
+	}
+}
+
+// Connections returns the number of connected clients.
+func (s *WebSocketServer) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.conns)
+}
+
+// Subscribed tells whether any connected client is subscribed to the given
+// channel and book.
+func (s *WebSocketServer) Subscribed(channel string, book bitso.Book) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.subscribedLocked(channel, book)
+}
+
+func (s *WebSocketServer) subscribedLocked(channel string, book bitso.Book) bool {
+	return slices.ContainsFunc(s.conns, func(c *wsConn) bool {
+		return c.subscribed(channel, book)
+	})
+}
+
+// WaitConnections blocks until at least n clients are connected or ctx is
+// done.
+func (s *WebSocketServer) WaitConnections(ctx context.Context, n int) error {
+	return s.wait(ctx, func() bool {
+		return len(s.conns) >= n
+	})
+}
+
+// WaitSubscribed blocks until a client subscribes to the given channel and
+// book or ctx is done.
+func (s *WebSocketServer) WaitSubscribed(ctx context.Context, channel string, book bitso.Book) error {
+	return s.wait(ctx, func() bool {
+		return s.subscribedLocked(channel, book)
+	})
+}
+
+func (s *WebSocketServer) wait(ctx context.Context, cond func() bool) error {
+	for {
+		s.mu.Lock()
+		ok, changed := cond(), s.changed
+		s.mu.Unlock()
+
+		if ok {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *WebSocketServer) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *WebSocketServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	reject := s.reject
+	s.mu.Unlock()
+
+	if reject {
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &wsConn{conn: conn}
+
+	s.mu.Lock()
+	s.conns = append(s.conns, c)
+	s.notifyLocked()
+	s.mu.Unlock()
+
+	defer s.remove(c)
+
+	for {
+		var m bitso.WebSocketMessage
+		if err := conn.ReadJSON(&m); err != nil {
+			return
+		}
+		if m.Action != "subscribe" {
+			continue
+		}
+
+		reply := bitso.WebSocketReply{
+			Action:   m.Action,
+			Response: "ok",
+			Time:     uint64(time.Now().UnixMilli()),
+			Type:     m.Type,
+		}
+		data, err := json.Marshal(reply)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if m.Book != nil && !c.subscribed(m.Type, *m.Book) {
+			c.subscriptions = append(c.subscriptions, m)
+		}
+		s.notifyLocked()
+		s.mu.Unlock()
+
+		if err := c.write(data); err != nil {
+			return
+		}
+	}
+}
+
+func (s *WebSocketServer) remove(c *wsConn) {
+	c.conn.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := slices.Index(s.conns, c); i >= 0 {
+		s.conns = slices.Delete(s.conns, i, i+1)
+		s.notifyLocked()
+	}
+}
+
+// broadcast writes data to the clients selected by match.
+func (s *WebSocketServer) broadcast(data []byte, match func(*wsConn) bool) error {
+	s.mu.Lock()
+	var targets []*wsConn
+	for _, c := range s.conns {
+		if match(c) {
+			targets = append(targets, c)
+		}
+	}
+	s.mu.Unlock()
+
+	var errs []error
+	for _, c := range targets {
+		errs = append(errs, c.write(data))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *WebSocketServer) push(channel string, book bitso.Book, msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return s.broadcast(data, func(c *wsConn) bool {
+		return c.subscribed(channel, book)
+	})
+}
+
+// PushTrades sends a "trades" message to the clients subscribed to the
+// trades of book.
+func (s *WebSocketServer) PushTrades(book bitso.Book, trades ...bitso.StreamTrade) error {
+	return s.push("trades", book, struct {
+		Type string `json:"type"`
+		bitso.WebSocketTrade
+	}{"trades", bitso.WebSocketTrade{Book: book, Payload: trades}})
+}
+
+// PushDiffOrders is like PushTrades but sends a "diff-orders" message with the
+// given sequence number.
+func (s *WebSocketServer) PushDiffOrders(book bitso.Book, sequence uint64, diffs ...bitso.StreamOrderDiff) error {
+	return s.push("diff-orders", book, struct {
+		Type string `json:"type"`
+		bitso.WebSocketDiffOrder
+	}{"diff-orders", bitso.WebSocketDiffOrder{Book: book, Sequence: sequence, Payload: diffs}})
+}
+
+// PushOrders is like PushTrades but sends an "orders" snapshot with the given
+// sequence number.
+func (s *WebSocketServer) PushOrders(book bitso.Book, sequence uint64, bids, asks []bitso.StreamOrder) error {
+	msg := bitso.WebSocketOrder{Book: book, Sequence: sequence}
+	msg.Payload.Bids = bids
+	msg.Payload.Asks = asks
+
+	return s.push("orders", book, struct {
+		Type string `json:"type"`
+		bitso.WebSocketOrder
+	}{"orders", msg})
+}
+
+// PushKeepAlive sends a keep alive message to all clients.
+func (s *WebSocketServer) PushKeepAlive() error {
+	return s.PushRaw([]byte(`{"type":"ka"}`))
+}
+
+// PushRaw sends data as is to all clients, use it to send malformed or
+// unusual frames.
+func (s *WebSocketServer) PushRaw(data []byte) error {
+	return s.broadcast(data, func(*wsConn) bool {
+		return true
+	})
+}
@@ -0,0 +1,173 @@
+package bitsotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xiam/bitso-go/bitso"
+)
+
+func dial(t *testing.T, server *WebSocketServer, attempts int) *bitso.WebSocketConn {
+	t.Helper()
+
+	ws, err := bitso.NewWebSocketConn(
+		bitso.WithWebSocketURL(server.URL),
+		bitso.WithWebSocketLogger(zerolog.Nop()),
+		bitso.WithReconnectPolicy(&bitso.RetryPolicy{
+			MaxAttempts: attempts,
+			MinBackoff:  time.Millisecond,
+			MaxBackoff:  5 * time.Millisecond,
+		}),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ws.Close()
+	})
+	return ws
+}
+
+func withTimeout(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestWebSocketServer_Streams(t *testing.T) {
+	server := NewWebSocketServer()
+	defer server.Close()
+
+	ws := dial(t, server, 3)
+	book := *bitso.NewBook(bitso.BTC, bitso.MXN)
+
+	trades, err := ws.SubscribeTrades(&book)
+	require.NoError(t, err)
+	diffs, err := ws.SubscribeDiffOrders(&book)
+	require.NoError(t, err)
+	orders, err := ws.SubscribeOrders(&book)
+	require.NoError(t, err)
+
+	ctx := withTimeout(t)
+	for _, channel := range []string{"trades", "diff-orders", "orders"} {
+		require.NoError(t, server.WaitSubscribed(ctx, channel, book))
+	}
+	assert.False(t, server.Subscribed("trades", *bitso.NewBook(bitso.ETH, bitso.MXN)))
+
+	createdAt := time.UnixMilli(1705312200000)
+
+	require.NoError(t, server.PushKeepAlive())
+	require.NoError(t, server.PushTrades(book, bitso.StreamTrade{
+		TID:       1,
+		Amount:    "0.5",
+		Price:     "500000",
+		Value:     "250000",
+		MakerSide: bitso.OrderSideSell,
+		CreatedAt: createdAt,
+	}))
+	require.NoError(t, server.PushDiffOrders(book, 7, bitso.StreamOrderDiff{
+		OrderID: "a",
+		Side:    bitso.OrderSideBuy,
+		Price:   "499000",
+		Amount:  "1",
+		Status:  bitso.OrderStatusOpen,
+	}))
+	require.NoError(t, server.PushOrders(book, 8,
+		[]bitso.StreamOrder{{OrderID: "b", Side: bitso.OrderSideBuy, Price: "499000", Amount: "1"}},
+		[]bitso.StreamOrder{{OrderID: "c", Side: bitso.OrderSideSell, Price: "501000", Amount: "2"}},
+	))
+
+	trade := <-trades
+	assert.Equal(t, book, trade.Book)
+	require.Len(t, trade.Payload, 1)
+	assert.Equal(t, bitso.TID(1), trade.Payload[0].TID)
+	assert.Equal(t, bitso.OrderSideSell, trade.Payload[0].MakerSide)
+	assert.True(t, createdAt.Equal(trade.Payload[0].CreatedAt))
+
+	diff := <-diffs
+	assert.Equal(t, uint64(7), diff.Sequence)
+	require.Len(t, diff.Payload, 1)
+	assert.Equal(t, bitso.OrderStatusOpen, diff.Payload[0].Status)
+
+	snapshot := <-orders
+	assert.Equal(t, uint64(8), snapshot.Sequence)
+	require.Len(t, snapshot.Payload.Asks, 1)
+	assert.Equal(t, "c", snapshot.Payload.Asks[0].OrderID)
+	assert.False(t, ws.LastMessageAt().IsZero())
+}
+
+func TestWebSocketServer_Disconnect(t *testing.T) {
+	server := NewWebSocketServer()
+	defer server.Close()
+
+	ws := dial(t, server, 5)
+	book := *bitso.NewBook(bitso.ETH, bitso.MXN)
+
+	trades, err := ws.SubscribeTrades(&book)
+	require.NoError(t, err)
+
+	ctx := withTimeout(t)
+	require.NoError(t, server.WaitSubscribed(ctx, "trades", book))
+
+	server.Disconnect()
+	assert.Equal(t, 0, server.Connections())
+
+	// The client reconnects and subscribes again.
+	require.NoError(t, server.WaitSubscribed(ctx, "trades", book))
+	assert.Equal(t, 1, server.Connections())
+
+	require.NoError(t, server.PushTrades(book, bitso.StreamTrade{TID: 2}))
+
+	select {
+	case trade := <-trades:
+		assert.Equal(t, bitso.TID(2), trade.Payload[0].TID)
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for trade")
+	}
+}
+
+func TestWebSocketServer_MalformedFrames(t *testing.T) {
+	server := NewWebSocketServer()
+	defer server.Close()
+
+	ws := dial(t, server, 3)
+	require.NoError(t, server.WaitConnections(withTimeout(t), 1))
+
+	require.NoError(t, server.PushRaw([]byte(`{"type": "trades", "payload": "oops"}`)))
+
+	select {
+	case err := <-ws.Errors():
+		var decodeErr *bitso.WebSocketDecodeError
+		assert.ErrorAs(t, err, &decodeErr)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for decode error")
+	}
+}
+
+func TestWebSocketServer_Reject(t *testing.T) {
+	server := NewWebSocketServer()
+	defer server.Close()
+
+	ws := dial(t, server, 2)
+
+	book := *bitso.NewBook(bitso.BTC, bitso.MXN)
+	trades, err := ws.SubscribeTrades(&book)
+	require.NoError(t, err)
+	require.NoError(t, server.WaitSubscribed(withTimeout(t), "trades", book))
+
+	server.Reject(true)
+	server.Disconnect()
+
+	select {
+	case _, ok := <-trades:
+		assert.False(t, ok, "channel must be closed after giving up")
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for the connection to give up")
+	}
+
+	_, err = bitso.NewWebSocketConn(bitso.WithWebSocketURL(server.URL))
+	assert.Error(t, err)
+}
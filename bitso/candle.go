@@ -0,0 +1,246 @@
+package bitso
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Candle holds the OHLCV summary of the trades of a book made within an
+// interval.
+type Candle struct {
+	// Order book symbol
+	Book Book `json:"book"`
+
+	// Start of the interval, inclusive
+	Start Time `json:"start"`
+
+	// End of the interval, exclusive
+	End Time `json:"end"`
+
+	// Price of the first trade
+	Open Monetary `json:"open"`
+
+	// Highest traded price
+	High Monetary `json:"high"`
+
+	// Lowest traded price
+	Low Monetary `json:"low"`
+
+	// Price of the last trade
+	Close Monetary `json:"close"`
+
+	// Amount traded, in the major currency
+	Volume Monetary `json:"volume"`
+
+	// Amount traded, in the minor currency
+	Value Monetary `json:"value"`
+
+	// Volume weighted average price
+	Vwap Monetary `json:"vwap"`
+
+	// Number of trades
+	Trades int `json:"trades"`
+
+	// TIDs of the first and last trades
+	FirstTID TID `json:"first_tid"`
+	LastTID  TID `json:"last_tid"`
+}
+
+type candleState struct {
+	start time.Time
+
+	open, close   decimal.Decimal
+	high, low     decimal.Decimal
+	volume, value decimal.Decimal
+
+	first, last uint64
+	tids        map[uint64]struct{}
+}
+
+func (s *candleState) add(tid uint64, price, amount decimal.Decimal) {
+	if len(s.tids) == 0 {
+		s.open, s.close, s.high, s.low = price, price, price, price
+		s.first, s.last = tid, tid
+	}
+	s.tids[tid] = struct{}{}
+
+	// Trades may arrive out of order, open and close follow the TIDs.
+	if tid < s.first {
+		s.open, s.first = price, tid
+	}
+	if tid > s.last {
+		s.close, s.last = price, tid
+	}
+	s.high = decimal.Max(s.high, price)
+	s.low = decimal.Min(s.low, price)
+	s.volume = s.volume.Add(amount)
+	s.value = s.value.Add(amount.Mul(price))
+}
+
+// A CandleAggregator builds the candles of a book from its trades. Trades can
+// be added in any order: repeated TIDs are ignored and late trades update the
+// candle they belong to.
+//
+// Candles remember the TIDs of their trades to tell repeated ones apart, so
+// memory grows with every trade added until Prune drops old candles.
+type CandleAggregator struct {
+	book     Book
+	interval time.Duration
+
+	candles map[time.Time]*candleState
+	// pruned is the time before which trades are discarded.
+	pruned time.Time
+
+	mu sync.Mutex
+}
+
+// NewCandleAggregator creates an aggregator of candles of the given interval
+// for the given book. Candles start at multiples of interval since the zero
+// time, so an interval of an hour starts them at the top of the hour. It
+// panics if interval is not positive.
+func NewCandleAggregator(book Book, interval time.Duration) *CandleAggregator {
+	if interval <= 0 {
+		panic("bitso: non-positive interval for NewCandleAggregator")
+	}
+	return &CandleAggregator{
+		book:     book,
+		interval: interval,
+		candles:  map[time.Time]*candleState{},
+	}
+}
+
+// Add aggregates the given trades and returns the candles that changed,
+// sorted by start time. Trades of other books are ignored.
+func (a *CandleAggregator) Add(trades ...Trade) []Candle {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	changed := map[time.Time]*candleState{}
+	for _, trade := range trades {
+		if trade.Book != a.book {
+			continue
+		}
+		price, err := trade.Price.Decimal()
+		if err != nil {
+			continue
+		}
+		amount, err := trade.Amount.Decimal()
+		if err != nil {
+			continue
+		}
+
+		createdAt := trade.CreatedAt.Time().UTC()
+		if createdAt.Before(a.pruned) {
+			continue
+		}
+		start := createdAt.Truncate(a.interval)
+
+		state, ok := a.candles[start]
+		if !ok {
+			state = &candleState{start: start, tids: map[uint64]struct{}{}}
+			a.candles[start] = state
+		}
+		tid := trade.TID.Uint64()
+		if _, ok := state.tids[tid]; ok {
+			continue
+		}
+		state.add(tid, price, amount.Abs())
+		changed[start] = state
+	}
+
+	return a.sorted(changed)
+}
+
+// AddWebSocketTrade aggregates the trades of a message from the "trades"
+// channel, see Add.
+func (a *CandleAggregator) AddWebSocketTrade(msg WebSocketTrade) []Candle {
+	return a.Add(msg.Trades()...)
+}
+
+// Candles returns all the candles kept, sorted by start time. Intervals with
+// no trades have no candle.
+func (a *CandleAggregator) Candles() []Candle {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.sorted(a.candles)
+}
+
+// Prune drops the candles that end at or before the given time. Trades made
+// before it are ignored from then on.
+func (a *CandleAggregator) Prune(before time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	before = before.UTC().Truncate(a.interval)
+	for start := range a.candles {
+		if start.Before(before) {
+			delete(a.candles, start)
+		}
+	}
+	if before.After(a.pruned) {
+		a.pruned = before
+	}
+}
+
+// Backfill aggregates the trades of the book made since the given time,
+// paginating over md.TradesContext and adding them a page at a time. Trades
+// already added are not counted again.
+func (a *CandleAggregator) Backfill(ctx context.Context, md MarketData, since time.Time) error {
+	params := url.Values{"book": {a.book.String()}}
+	trades := paginate(ctx, params, since, md.TradesContext,
+		func(trade Trade) string { return tidMarker(trade.TID) },
+		func(trade Trade) time.Time { return trade.CreatedAt.Time() },
+	)
+
+	page := make([]Trade, 0, maxPageSize)
+	for trade, err := range trades {
+		if err != nil {
+			return err
+		}
+		if page = append(page, trade); len(page) == maxPageSize {
+			a.Add(page...)
+			page = page[:0]
+		}
+	}
+	a.Add(page...)
+	return nil
+}
+
+func (a *CandleAggregator) sorted(states map[time.Time]*candleState) []Candle {
+	candles := make([]Candle, 0, len(states))
+	for _, state := range states {
+		candles = append(candles, a.candle(state))
+	}
+	sort.Slice(candles, func(i, j int) bool {
+		return candles[i].Start.Time().Before(candles[j].Start.Time())
+	})
+	return candles
+}
+
+func (a *CandleAggregator) candle(s *candleState) Candle {
+	vwap := decimal.Zero
+	if !s.volume.IsZero() {
+		vwap = s.value.DivRound(s.volume, 8)
+	}
+	return Candle{
+		Book:     a.book,
+		Start:    Time(s.start),
+		End:      Time(s.start.Add(a.interval)),
+		Open:     Monetary(s.open.String()),
+		High:     Monetary(s.high.String()),
+		Low:      Monetary(s.low.String()),
+		Close:    Monetary(s.close.String()),
+		Volume:   Monetary(s.volume.String()),
+		Value:    Monetary(s.value.String()),
+		Vwap:     Monetary(vwap.String()),
+		Trades:   len(s.tids),
+		FirstTID: TID(s.first),
+		LastTID:  TID(s.last),
+	}
+}
@@ -0,0 +1,164 @@
+package bitso
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func candleTrade(tid uint64, at time.Time, price, amount Monetary) Trade {
+	return Trade{
+		Book:      *NewBook(BTC, MXN),
+		CreatedAt: Time(at),
+		Amount:    amount,
+		Price:     price,
+		TID:       TID(tid),
+	}
+}
+
+func TestCandleAggregator_Add(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	a := NewCandleAggregator(*NewBook(BTC, MXN), time.Minute)
+
+	changed := a.Add(
+		candleTrade(1, base.Add(5*time.Second), "500000", "0.1"),
+		candleTrade(2, base.Add(20*time.Second), "510000", "0.2"),
+		candleTrade(3, base.Add(40*time.Second), "490000", "0.1"),
+		candleTrade(4, base.Add(55*time.Second), "505000", "0.1"),
+		candleTrade(5, base.Add(70*time.Second), "506000", "1"),
+		// Other books are ignored.
+		Trade{Book: *NewBook(ETH, MXN), CreatedAt: Time(base), Amount: "1", Price: "50000", TID: 6},
+	)
+	require.Len(t, changed, 2)
+
+	candle := changed[0]
+	assert.Equal(t, *NewBook(BTC, MXN), candle.Book)
+	assert.Equal(t, base, candle.Start.Time())
+	assert.Equal(t, base.Add(time.Minute), candle.End.Time())
+	assert.Equal(t, Monetary("500000"), candle.Open)
+	assert.Equal(t, Monetary("510000"), candle.High)
+	assert.Equal(t, Monetary("490000"), candle.Low)
+	assert.Equal(t, Monetary("505000"), candle.Close)
+	assert.Equal(t, Monetary("0.5"), candle.Volume)
+	// 50000 + 102000 + 49000 + 50500
+	assert.Equal(t, Monetary("251500"), candle.Value)
+	assert.Equal(t, Monetary("503000"), candle.Vwap)
+	assert.Equal(t, 4, candle.Trades)
+	assert.Equal(t, TID(1), candle.FirstTID)
+	assert.Equal(t, TID(4), candle.LastTID)
+
+	candle = changed[1]
+	assert.Equal(t, base.Add(time.Minute), candle.Start.Time())
+	assert.Equal(t, Monetary("506000"), candle.Open)
+	assert.Equal(t, Monetary("506000"), candle.Close)
+	assert.Equal(t, 1, candle.Trades)
+
+	assert.Equal(t, changed, a.Candles())
+}
+
+func TestCandleAggregator_OutOfOrder(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	a := NewCandleAggregator(*NewBook(BTC, MXN), time.Minute)
+
+	a.Add(
+		candleTrade(12, base.Add(30*time.Second), "502000", "0.1"),
+		candleTrade(20, base.Add(90*time.Second), "503000", "0.1"),
+	)
+
+	// A late trade updates the candle it belongs to, open and close follow
+	// the TIDs, and repeated TIDs are not counted again.
+	changed := a.Add(
+		candleTrade(10, base.Add(10*time.Second), "501000", "0.1"),
+		candleTrade(12, base.Add(30*time.Second), "502000", "0.1"),
+		candleTrade(11, base.Add(50*time.Second), "499000", "0.1"),
+	)
+	require.Len(t, changed, 1)
+
+	candle := changed[0]
+	assert.Equal(t, base, candle.Start.Time())
+	assert.Equal(t, Monetary("501000"), candle.Open)
+	assert.Equal(t, Monetary("502000"), candle.Close)
+	assert.Equal(t, Monetary("499000"), candle.Low)
+	assert.Equal(t, Monetary("0.3"), candle.Volume)
+	assert.Equal(t, 3, candle.Trades)
+	assert.Equal(t, TID(10), candle.FirstTID)
+	assert.Equal(t, TID(12), candle.LastTID)
+
+	assert.Empty(t, a.Add(candleTrade(20, base.Add(90*time.Second), "503000", "0.1")))
+	assert.Len(t, a.Candles(), 2)
+
+	a.Prune(base.Add(time.Minute))
+	candles := a.Candles()
+	require.Len(t, candles, 1)
+	assert.Equal(t, base.Add(time.Minute), candles[0].Start.Time())
+
+	// Trades of pruned candles are dropped.
+	assert.Empty(t, a.Add(candleTrade(9, base, "500000", "0.1")))
+}
+
+func TestCandleAggregator_AddWebSocketTrade(t *testing.T) {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	a := NewCandleAggregator(*NewBook(BTC, MXN), time.Hour)
+
+	changed := a.AddWebSocketTrade(WebSocketTrade{
+		Book: *NewBook(BTC, MXN),
+		Payload: []StreamTrade{
+			{TID: 1, Price: "500000", Amount: "0.3", CreatedAt: base.Add(time.Minute)},
+			{TID: 2, Price: "500001", Amount: "0.7", CreatedAt: base.Add(2 * time.Minute)},
+		},
+	})
+	require.Len(t, changed, 1)
+	assert.Equal(t, Monetary("1"), changed[0].Volume)
+	assert.Equal(t, Monetary("500000.7"), changed[0].Vwap)
+	assert.Equal(t, 2, changed[0].Trades)
+}
+
+func TestCandleAggregator_Backfill(t *testing.T) {
+	var requests int32
+	server, client := mockServer(t, pagedTrades(t, 150, &requests))
+	defer server.Close()
+
+	// Trade N was created N minutes after the base time.
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	a := NewCandleAggregator(*NewBook(BTC, MXN), time.Hour)
+
+	var md MarketData = client
+	require.NoError(t, a.Backfill(context.Background(), md, base.Add(35*time.Minute)))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	candles := a.Candles()
+	require.Len(t, candles, 3)
+	for i, tc := range []struct {
+		first, last TID
+		trades      int
+		volume      Monetary
+	}{
+		{35, 59, 25, "2.5"},
+		{60, 119, 60, "6"},
+		{120, 150, 31, "3.1"},
+	} {
+		assert.Equal(t, base.Add(time.Duration(i)*time.Hour), candles[i].Start.Time())
+		assert.Equal(t, tc.first, candles[i].FirstTID)
+		assert.Equal(t, tc.last, candles[i].LastTID)
+		assert.Equal(t, tc.trades, candles[i].Trades)
+		assert.Equal(t, tc.volume, candles[i].Volume)
+		assert.Equal(t, Monetary("500000"), candles[i].Vwap)
+	}
+
+	// Backfilling again doesn't count trades twice.
+	require.NoError(t, a.Backfill(context.Background(), client, base.Add(35*time.Minute)))
+	assert.Equal(t, candles, a.Candles())
+}
+
+func TestNewCandleAggregator_Interval(t *testing.T) {
+	assert.Panics(t, func() {
+		NewCandleAggregator(*NewBook(BTC, MXN), 0)
+	})
+	assert.Panics(t, func() {
+		NewCandleAggregator(*NewBook(BTC, MXN), -time.Minute)
+	})
+}
@@ -2,6 +2,7 @@ package bitso
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/json"
@@ -11,6 +12,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -34,7 +36,11 @@ const (
 	LogLevelTrace = zerolog.TraceLevel
 )
 
-const defaultTickets = 1
+// maxCancelBatch is the maximum number of order IDs sent in a single cancel
+// request.
+const maxCancelBatch = 20
+
+var errMissingBook = errors.New("missing book")
 
 // maxResponseSize limits the maximum response body size to prevent DoS attacks
 const maxResponseSize = 10 * 1024 * 1024 // 10MB
@@ -55,9 +61,11 @@ type Client struct {
 	key    string
 	secret string
 
-	tickets chan struct{}
-
+	limiter   RateLimiter
 	burstRate time.Duration
+	burst     *tokenBucket
+
+	retryPolicy *RetryPolicy
 
 	mu sync.RWMutex
 }
@@ -66,7 +74,6 @@ type Client struct {
 func NewClient() *Client {
 	c := &Client{
 		client:    http.DefaultClient,
-		tickets:   make(chan struct{}, defaultTickets),
 		baseURL:   strings.TrimPrefix(apiBaseURL, "/") + "/",
 		logger:    zerolog.New(os.Stderr).With().Timestamp().Logger(),
 		version:   apiVersion,
@@ -75,9 +82,6 @@ func NewClient() *Client {
 
 	c.SetLogLevel(LogLevelInfo)
 
-	for i := 0; i < defaultTickets; i++ {
-		c.tickets <- struct{}{}
-	}
 	return c
 }
 
@@ -118,10 +122,15 @@ func (c *Client) APIBaseURL() string {
 // AvailableBooks returns a list of existing exchange order books and their
 // respective order placement limits.
 func (c *Client) AvailableBooks() ([]ExchangeOrderBook, error) {
+	return c.AvailableBooksContext(context.Background())
+}
+
+// AvailableBooksContext is like AvailableBooks but carries a context.
+func (c *Client) AvailableBooksContext(ctx context.Context) ([]ExchangeOrderBook, error) {
 	res := struct {
 		Payload []ExchangeOrderBook `json:"payload"`
 	}{}
-	if err := c.getResponse("/available_books", nil, &res); err != nil {
+	if err := c.getResponse(ctx, "/available_books", nil, &res); err != nil {
 		return nil, err
 	}
 	return res.Payload, nil
@@ -129,10 +138,15 @@ func (c *Client) AvailableBooks() ([]ExchangeOrderBook, error) {
 
 // Tickers returns trading information from all books.
 func (c *Client) Tickers() ([]Ticker, error) {
+	return c.TickersContext(context.Background())
+}
+
+// TickersContext is like Tickers but carries a context.
+func (c *Client) TickersContext(ctx context.Context) ([]Ticker, error) {
 	res := struct {
 		Payload []Ticker `json:"payload"`
 	}{}
-	if err := c.getResponse("/ticker", nil, &res); err != nil {
+	if err := c.getResponse(ctx, "/ticker", nil, &res); err != nil {
 		return nil, err
 	}
 	return res.Payload, nil
@@ -140,13 +154,18 @@ func (c *Client) Tickers() ([]Ticker, error) {
 
 // Ticker returns trading information from the specified book.
 func (c *Client) Ticker(book *Book) (*Ticker, error) {
+	return c.TickerContext(context.Background(), book)
+}
+
+// TickerContext is like Ticker but carries a context.
+func (c *Client) TickerContext(ctx context.Context, book *Book) (*Ticker, error) {
 	params := url.Values{
 		"book": {book.String()},
 	}
 	res := struct {
 		Payload Ticker `json:"payload"`
 	}{}
-	if err := c.getResponse("/ticker", params, &res); err != nil {
+	if err := c.getResponse(ctx, "/ticker", params, &res); err != nil {
 		return nil, err
 	}
 	return &res.Payload, nil
@@ -154,10 +173,15 @@ func (c *Client) Ticker(book *Book) (*Ticker, error) {
 
 // Trades returns a list of recent trades from the specified book.
 func (c *Client) Trades(params url.Values) ([]Trade, error) {
+	return c.TradesContext(context.Background(), params)
+}
+
+// TradesContext is like Trades but carries a context.
+func (c *Client) TradesContext(ctx context.Context, params url.Values) ([]Trade, error) {
 	res := struct {
 		Payload []Trade `json:"payload"`
 	}{}
-	if err := c.getResponse("/trades", params, &res); err != nil {
+	if err := c.getResponse(ctx, "/trades", params, &res); err != nil {
 		return nil, err
 	}
 	return res.Payload, nil
@@ -165,10 +189,15 @@ func (c *Client) Trades(params url.Values) ([]Trade, error) {
 
 // OrderBook returns a list of all open orders in the specified book.
 func (c *Client) OrderBook(params url.Values) (*OrderBook, error) {
+	return c.OrderBookContext(context.Background(), params)
+}
+
+// OrderBookContext is like OrderBook but carries a context.
+func (c *Client) OrderBookContext(ctx context.Context, params url.Values) (*OrderBook, error) {
 	res := struct {
 		Payload OrderBook `json:"payload"`
 	}{}
-	if err := c.getResponse("/order_book", params, &res); err != nil {
+	if err := c.getResponse(ctx, "/order_book", params, &res); err != nil {
 		return nil, err
 	}
 	return &res.Payload, nil
@@ -177,12 +206,17 @@ func (c *Client) OrderBook(params url.Values) (*OrderBook, error) {
 // Balances returns information concerning the user’s balances for all supported
 // currencies.
 func (c *Client) Balances(params url.Values) ([]Balance, error) {
+	return c.BalancesContext(context.Background(), params)
+}
+
+// BalancesContext is like Balances but carries a context.
+func (c *Client) BalancesContext(ctx context.Context, params url.Values) ([]Balance, error) {
 	res := struct {
 		Payload struct {
 			Balances []Balance `json:"balances"`
 		} `json:"payload"`
 	}{}
-	if err := c.getResponse("/balance", params, &res); err != nil {
+	if err := c.getResponse(ctx, "/balance", params, &res); err != nil {
 		return nil, err
 	}
 	return res.Payload.Balances, nil
@@ -191,10 +225,15 @@ func (c *Client) Balances(params url.Values) ([]Balance, error) {
 // Fees returns information on customer fees for all available order books,
 // and withdrawal fees for applicable currencies.
 func (c *Client) Fees(params url.Values) (*CustomerFees, error) {
+	return c.FeesContext(context.Background(), params)
+}
+
+// FeesContext is like Fees but carries a context.
+func (c *Client) FeesContext(ctx context.Context, params url.Values) (*CustomerFees, error) {
 	res := struct {
 		Payload CustomerFees `json:"payload"`
 	}{}
-	if err := c.getResponse("/fees", params, &res); err != nil {
+	if err := c.getResponse(ctx, "/fees", params, &res); err != nil {
 		return nil, err
 	}
 	return &res.Payload, nil
@@ -202,10 +241,15 @@ func (c *Client) Fees(params url.Values) (*CustomerFees, error) {
 
 // Ledger returns a list of all the user's registered operations.
 func (c *Client) Ledger(params url.Values) ([]Transaction, error) {
+	return c.LedgerContext(context.Background(), params)
+}
+
+// LedgerContext is like Ledger but carries a context.
+func (c *Client) LedgerContext(ctx context.Context, params url.Values) ([]Transaction, error) {
 	res := struct {
 		Payload []Transaction `json:"payload"`
 	}{}
-	if err := c.getResponse("/ledger", params, &res); err != nil {
+	if err := c.getResponse(ctx, "/ledger", params, &res); err != nil {
 		return nil, err
 	}
 	return res.Payload, nil
@@ -213,6 +257,11 @@ func (c *Client) Ledger(params url.Values) ([]Transaction, error) {
 
 // LedgerByOperation returns a list of all the user's registered operations.
 func (c *Client) LedgerByOperation(op Operation, params url.Values) ([]Transaction, error) {
+	return c.LedgerByOperationContext(context.Background(), op, params)
+}
+
+// LedgerByOperationContext is like LedgerByOperation but carries a context.
+func (c *Client) LedgerByOperationContext(ctx context.Context, op Operation, params url.Values) ([]Transaction, error) {
 	optype := map[Operation]string{
 		OperationFunding:    "fundings",
 		OperationWithdrawal: "withdrawals",
@@ -222,7 +271,7 @@ func (c *Client) LedgerByOperation(op Operation, params url.Values) ([]Transacti
 	res := struct {
 		Payload []Transaction `json:"payload"`
 	}{}
-	if err := c.getResponse("/ledger/"+optype[op], params, &res); err != nil {
+	if err := c.getResponse(ctx, "/ledger/"+optype[op], params, &res); err != nil {
 		return nil, err
 	}
 	return res.Payload, nil
@@ -230,10 +279,15 @@ func (c *Client) LedgerByOperation(op Operation, params url.Values) ([]Transacti
 
 // Fundings returns detailed info on a user's fundings.
 func (c *Client) Fundings(params url.Values) ([]Funding, error) {
+	return c.FundingsContext(context.Background(), params)
+}
+
+// FundingsContext is like Fundings but carries a context.
+func (c *Client) FundingsContext(ctx context.Context, params url.Values) ([]Funding, error) {
 	res := struct {
 		Payload []Funding `json:"payload"`
 	}{}
-	if err := c.getResponse("/fundings/", params, &res); err != nil {
+	if err := c.getResponse(ctx, "/fundings/", params, &res); err != nil {
 		return nil, err
 	}
 	return res.Payload, nil
@@ -241,10 +295,15 @@ func (c *Client) Fundings(params url.Values) ([]Funding, error) {
 
 // Withdrawals returns detailed info on user's withdrawals
 func (c *Client) Withdrawals(params url.Values) ([]Withdrawal, error) {
+	return c.WithdrawalsContext(context.Background(), params)
+}
+
+// WithdrawalsContext is like Withdrawals but carries a context.
+func (c *Client) WithdrawalsContext(ctx context.Context, params url.Values) ([]Withdrawal, error) {
 	res := struct {
 		Payload []Withdrawal `json:"payload"`
 	}{}
-	if err := c.getResponse("/withdrawals", params, &res); err != nil {
+	if err := c.getResponse(ctx, "/withdrawals", params, &res); err != nil {
 		return nil, err
 	}
 	return res.Payload, nil
@@ -252,10 +311,15 @@ func (c *Client) Withdrawals(params url.Values) ([]Withdrawal, error) {
 
 // MyTrades returns a list of the user's trades.
 func (c *Client) MyTrades(params url.Values) ([]UserTrade, error) {
+	return c.MyTradesContext(context.Background(), params)
+}
+
+// MyTradesContext is like MyTrades but carries a context.
+func (c *Client) MyTradesContext(ctx context.Context, params url.Values) ([]UserTrade, error) {
 	res := struct {
 		Payload []UserTrade `json:"payload"`
 	}{}
-	if err := c.getResponse("/user_trades", params, &res); err != nil {
+	if err := c.getResponse(ctx, "/user_trades", params, &res); err != nil {
 		return nil, err
 	}
 	return res.Payload, nil
@@ -263,10 +327,15 @@ func (c *Client) MyTrades(params url.Values) ([]UserTrade, error) {
 
 // OrderTrades returns a list of the user's order trades on a given order.
 func (c *Client) OrderTrades(oid string, params url.Values) ([]UserOrderTrade, error) {
+	return c.OrderTradesContext(context.Background(), oid, params)
+}
+
+// OrderTradesContext is like OrderTrades but carries a context.
+func (c *Client) OrderTradesContext(ctx context.Context, oid string, params url.Values) ([]UserOrderTrade, error) {
 	res := struct {
 		Payload []UserOrderTrade `json:"payload"`
 	}{}
-	if err := c.getResponse("/order_trades/"+oid, params, &res); err != nil {
+	if err := c.getResponse(ctx, "/order_trades/"+oid, params, &res); err != nil {
 		return nil, err
 	}
 	return res.Payload, nil
@@ -274,10 +343,15 @@ func (c *Client) OrderTrades(oid string, params url.Values) ([]UserOrderTrade, e
 
 // MyOpenOrders a list of the user's open orders.
 func (c *Client) MyOpenOrders(params url.Values) ([]UserOrder, error) {
+	return c.MyOpenOrdersContext(context.Background(), params)
+}
+
+// MyOpenOrdersContext is like MyOpenOrders but carries a context.
+func (c *Client) MyOpenOrdersContext(ctx context.Context, params url.Values) ([]UserOrder, error) {
 	res := struct {
 		Payload []UserOrder `json:"payload"`
 	}{}
-	if err := c.getResponse("/open_orders", params, &res); err != nil {
+	if err := c.getResponse(ctx, "/open_orders", params, &res); err != nil {
 		return nil, err
 	}
 	return res.Payload, nil
@@ -285,22 +359,32 @@ func (c *Client) MyOpenOrders(params url.Values) ([]UserOrder, error) {
 
 // LookupOrder returns details of an order given its order ID.
 func (c *Client) LookupOrder(oid string) (*UserOrder, error) {
-	orders, err := c.LookupOrders([]string{oid})
+	return c.LookupOrderContext(context.Background(), oid)
+}
+
+// LookupOrderContext is like LookupOrder but carries a context.
+func (c *Client) LookupOrderContext(ctx context.Context, oid string) (*UserOrder, error) {
+	orders, err := c.LookupOrdersContext(ctx, []string{oid})
 	if err != nil {
 		return nil, err
 	}
 	if len(orders) > 0 {
 		return &orders[0], nil
 	}
-	return nil, errors.New("no such order")
+	return nil, ErrOrderNotFound
 }
 
 // LookupOrders returns a list of details for 1 or more orders
 func (c *Client) LookupOrders(oids []string) ([]UserOrder, error) {
+	return c.LookupOrdersContext(context.Background(), oids)
+}
+
+// LookupOrdersContext is like LookupOrders but carries a context.
+func (c *Client) LookupOrdersContext(ctx context.Context, oids []string) ([]UserOrder, error) {
 	res := struct {
 		Payload []UserOrder `json:"payload"`
 	}{}
-	if err := c.getResponse("/orders/"+strings.Join(oids, ","), nil, &res); err != nil {
+	if err := c.getResponse(ctx, "/orders/"+strings.Join(oids, ","), nil, &res); err != nil {
 		return nil, err
 	}
 	return res.Payload, nil
@@ -308,10 +392,15 @@ func (c *Client) LookupOrders(oids []string) ([]UserOrder, error) {
 
 // CancelOrders cancels open order(s)
 func (c *Client) CancelOrders(oids []string) ([]string, error) {
+	return c.CancelOrdersContext(context.Background(), oids)
+}
+
+// CancelOrdersContext is like CancelOrders but carries a context.
+func (c *Client) CancelOrdersContext(ctx context.Context, oids []string) ([]string, error) {
 	var res struct {
 		Payload []string `json:"payload"`
 	}
-	if err := c.deleteResponse("/orders/"+strings.Join(oids, ","), nil, &res); err != nil {
+	if err := c.deleteResponse(ctx, "/orders/"+strings.Join(oids, ","), nil, &res); err != nil {
 		return nil, err
 	}
 	return res.Payload, nil
@@ -319,18 +408,133 @@ func (c *Client) CancelOrders(oids []string) ([]string, error) {
 
 // CancelOrder cancels an open order
 func (c *Client) CancelOrder(oid string) ([]string, error) {
-	return c.CancelOrders([]string{oid})
+	return c.CancelOrderContext(context.Background(), oid)
+}
+
+// CancelOrderContext is like CancelOrder but carries a context.
+func (c *Client) CancelOrderContext(ctx context.Context, oid string) ([]string, error) {
+	return c.CancelOrdersContext(ctx, []string{oid})
+}
+
+// LookupOrdersByOriginID returns a list of details for 1 or more orders given
+// their client-supplied IDs.
+func (c *Client) LookupOrdersByOriginID(originIDs []string) ([]UserOrder, error) {
+	return c.LookupOrdersByOriginIDContext(context.Background(), originIDs)
+}
+
+// LookupOrdersByOriginIDContext is like LookupOrdersByOriginID but carries a
+// context.
+func (c *Client) LookupOrdersByOriginIDContext(ctx context.Context, originIDs []string) ([]UserOrder, error) {
+	params := url.Values{
+		"origin_ids": {strings.Join(originIDs, ",")},
+	}
+	res := struct {
+		Payload []UserOrder `json:"payload"`
+	}{}
+	if err := c.getResponse(ctx, "/orders", params, &res); err != nil {
+		return nil, err
+	}
+	return res.Payload, nil
+}
+
+// CancelOrdersByOriginID cancels open order(s) given their client-supplied
+// IDs. It returns the origin IDs of the cancelled orders.
+func (c *Client) CancelOrdersByOriginID(originIDs []string) ([]string, error) {
+	return c.CancelOrdersByOriginIDContext(context.Background(), originIDs)
+}
+
+// CancelOrdersByOriginIDContext is like CancelOrdersByOriginID but carries a
+// context.
+func (c *Client) CancelOrdersByOriginIDContext(ctx context.Context, originIDs []string) ([]string, error) {
+	params := url.Values{
+		"origin_ids": {strings.Join(originIDs, ",")},
+	}
+	var res struct {
+		Payload []string `json:"payload"`
+	}
+	if err := c.deleteResponse(ctx, "/orders", params, &res); err != nil {
+		return nil, err
+	}
+	return res.Payload, nil
+}
+
+// CancelAllOrders cancels all of the user's open orders and returns the IDs
+// of the cancelled orders.
+func (c *Client) CancelAllOrders() ([]string, error) {
+	return c.CancelAllOrdersContext(context.Background())
+}
+
+// CancelAllOrdersContext is like CancelAllOrders but carries a context.
+func (c *Client) CancelAllOrdersContext(ctx context.Context) ([]string, error) {
+	var res struct {
+		Payload []string `json:"payload"`
+	}
+	if err := c.deleteResponse(ctx, "/orders/all", nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Payload, nil
+}
+
+// CancelBookOrders cancels all of the user's open orders on the given book and
+// returns the IDs of the cancelled orders.
+//
+// Bitso has no endpoint to cancel the orders of a single book, so the open
+// orders on the book are listed first and then cancelled by ID in batches.
+// This is best-effort: orders placed after they are listed are not
+// cancelled. All batches are attempted even if some of them fail; the IDs of
+// the orders that were cancelled are returned along with the errors.
+func (c *Client) CancelBookOrders(book *Book) ([]string, error) {
+	return c.CancelBookOrdersContext(context.Background(), book)
+}
+
+// CancelBookOrdersContext is like CancelBookOrders but carries a context.
+func (c *Client) CancelBookOrdersContext(ctx context.Context, book *Book) ([]string, error) {
+	if book == nil {
+		return nil, errMissingBook
+	}
+	params := url.Values{
+		"book": {book.String()},
+	}
+
+	var oids []string
+	for order, err := range c.MyOpenOrdersSeq(ctx, params, time.Time{}) {
+		if err != nil {
+			return nil, err
+		}
+		oids = append(oids, order.OID)
+	}
+
+	var cancelled []string
+	var errs []error
+	for batch := range slices.Chunk(oids, maxCancelBatch) {
+		res, err := c.CancelOrdersContext(ctx, batch)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		cancelled = append(cancelled, res...)
+	}
+	return cancelled, errors.Join(errs...)
 }
 
 // PlaceOrder places a buy or sell order (both limit and market orders are
 // available)
 func (c *Client) PlaceOrder(order *OrderPlacement) (string, error) {
+	return c.PlaceOrderContext(context.Background(), order)
+}
+
+// PlaceOrderContext is like PlaceOrder but carries a context. If the context
+// is cancelled before a response is received the order may or may not have
+// been placed.
+func (c *Client) PlaceOrderContext(ctx context.Context, order *OrderPlacement) (string, error) {
 	var res struct {
 		Payload struct {
 			OID string `json:"oid"`
 		} `json:"payload"`
 	}
-	if err := c.postResponse("/orders/", order, &res); err != nil {
+	// Placing an order is only safe to retry when Bitso can tell attempts
+	// apart by their client-supplied ID.
+	if err := c.postResponse(ctx, "/orders/", order, order.OriginID != "", &res); err != nil {
 		return "", err
 	}
 	return res.Payload.OID, nil
@@ -345,26 +549,20 @@ func (c *Client) BurstRate() time.Duration {
 }
 
 // SetBurstRate sets the amount of time the client should wait in between
-// requests.
+// requests. The burst rate is enforced on top of the rate limiter, so it
+// doesn't matter whether SetRateLimiter is called before or after it.
+//
+// Deprecated: Use SetRateLimiter instead.
 func (c *Client) SetBurstRate(burstRate time.Duration) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.burstRate = burstRate
+	c.burst = newTokenBucket(1, burstRate)
 }
 
-func (c *Client) newRequest(logger *zerolog.Logger, method string, uri string, body io.Reader) (*http.Request, error) {
-	var buf []byte
-
-	if body != nil {
-		var err error
-		buf, err = io.ReadAll(body)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	req, err := http.NewRequest(method, uri, bytes.NewBuffer(buf))
+func (c *Client) newRequest(ctx context.Context, logger *zerolog.Logger, method string, uri string, buf []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, uri, bytes.NewBuffer(buf))
 	if err != nil {
 		return nil, err
 	}
@@ -406,7 +604,37 @@ func (c *Client) newRequest(logger *zerolog.Logger, method string, uri string, b
 	return req, nil
 }
 
-func (c *Client) doRequest(method string, endpoint string, params url.Values, body io.Reader, dest interface{}) error {
+func (c *Client) doRequest(ctx context.Context, method string, endpoint string, params url.Values, body []byte, idempotent bool, dest interface{}) error {
+	policy := c.RetryPolicy()
+
+	for attempt := 1; ; attempt++ {
+		status, err := c.doAttempt(ctx, method, endpoint, params, body, dest)
+		if err == nil {
+			return nil
+		}
+
+		if !idempotent || attempt >= policy.maxAttempts() || !policy.retryable(status, err) {
+			return err
+		}
+
+		wait := policy.backoff(attempt)
+		c.logger.Warn().
+			Err(err).
+			Str("method", method).
+			Str("endpoint", endpoint).
+			Int("attempt", attempt).
+			Dur("backoff", wait).
+			Msg("retrying request")
+
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// doAttempt sends a single signed request and decodes its response into
+// dest. The returned HTTP status is zero when no response was received.
+func (c *Client) doAttempt(ctx context.Context, method string, endpoint string, params url.Values, body []byte, dest interface{}) (int, error) {
 	logger := c.logger.With().
 		Str("method", method).
 		Str("endpoint", endpoint).
@@ -414,45 +642,56 @@ func (c *Client) doRequest(method string, endpoint string, params url.Values, bo
 
 	u, err := c.endpointURL(endpoint)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	u.RawQuery = params.Encode()
 
-	req, err := c.newRequest(&logger, method, u.String(), body)
-	if err != nil {
-		return err
+	// Wait for the rate limiter before signing the request, otherwise a
+	// request that waited longer could be sent with an older nonce.
+	scope := endpointScope(endpoint)
+	c.mu.RLock()
+	limiter, burst := c.limiter, c.burst
+	c.mu.RUnlock()
+	if err := burst.wait(ctx); err != nil {
+		return 0, err
+	}
+	if limiter != nil {
+		if err := limiter.Wait(ctx, scope); err != nil {
+			return 0, err
+		}
 	}
 
-	// Apply burst-rate protection.
-	if burstRate := c.BurstRate(); burstRate > 0 {
-		<-c.tickets
-		ticker := time.NewTicker(burstRate)
-
-		go func() {
-			<-ticker.C
-			ticker.Stop()
-
-			c.tickets <- struct{}{}
-		}()
+	req, err := c.newRequest(ctx, &logger, method, u.String(), body)
+	if err != nil {
+		return 0, err
 	}
 
 	res, err := c.client.Do(req)
 	if err != nil {
 		logger.Error().Err(err).Msg("request failed")
-		return err
+		return 0, err
 	}
 	defer res.Body.Close()
 
 	buf, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize))
 	if err != nil {
 		logger.Error().Err(err).Msg("can not read response body")
-		return err
+		return res.StatusCode, err
 	}
 
 	logger = logger.With().
 		Int("status", res.StatusCode).
 		Logger()
 
+	if res.StatusCode == http.StatusTooManyRequests && limiter != nil {
+		pause := retryAfter(res, defaultRateLimitPause)
+		logger.Warn().
+			Str("scope", scope.String()).
+			Dur("pause", pause).
+			Msg("rate limited")
+		limiter.Pause(scope, pause)
+	}
+
 	if logger.GetLevel() <= zerolog.DebugLevel {
 		logger = logger.With().
 			Str("body", string(buf)).
@@ -462,37 +701,43 @@ func (c *Client) doRequest(method string, endpoint string, params url.Values, bo
 	var env Envelope
 	if err := json.Unmarshal(buf, &env); err != nil {
 		logger.Error().Msg("can not unmarshal envelope")
-		return err
+		if res.StatusCode >= http.StatusBadRequest {
+			return res.StatusCode, httpError(res.StatusCode, buf)
+		}
+		return res.StatusCode, err
 	}
 
 	if !env.Success {
-		code, _ := strconv.Atoi(fmt.Sprintf("%v", env.Error.Code))
+		apiErr := envelopeError(&env, res.StatusCode, buf)
 		logger.Error().
-			Int("error.code", code).
-			Msgf("api error: %s", env.Error.Message)
-		return apiError(code, env.Error.Message)
+			Int("error.code", apiErr.Code()).
+			Str("error.raw_code", apiErr.RawCode()).
+			Msgf("api error: %s", apiErr.Message())
+		return res.StatusCode, apiErr
 	}
 
 	if err := json.Unmarshal(buf, dest); err != nil {
 		logger.Error().Msg("can not unmarshal payload")
-		return err
+		return res.StatusCode, err
 	}
 
-	return nil
+	return res.StatusCode, nil
 }
 
-func (c *Client) deleteResponse(endpoint string, params url.Values, dest interface{}) error {
-	return c.doRequest("DELETE", endpoint, params, nil, dest)
+func (c *Client) deleteResponse(ctx context.Context, endpoint string, params url.Values, dest interface{}) error {
+	return c.doRequest(ctx, "DELETE", endpoint, params, nil, true, dest)
 }
 
-func (c *Client) getResponse(endpoint string, params url.Values, dest interface{}) error {
-	return c.doRequest("GET", endpoint, params, nil, dest)
+func (c *Client) getResponse(ctx context.Context, endpoint string, params url.Values, dest interface{}) error {
+	return c.doRequest(ctx, "GET", endpoint, params, nil, true, dest)
 }
 
-func (c *Client) postResponse(endpoint string, body interface{}, dest interface{}) error {
+// postResponse sends body as a JSON encoded POST request. Requests are only
+// retried if idempotent is true.
+func (c *Client) postResponse(ctx context.Context, endpoint string, body interface{}, idempotent bool, dest interface{}) error {
 	buf, err := json.Marshal(body)
 	if err != nil {
 		return err
 	}
-	return c.doRequest("POST", endpoint, nil, bytes.NewBuffer(buf), dest)
+	return c.doRequest(ctx, "POST", endpoint, nil, buf, idempotent, dest)
 }
@@ -1,10 +1,13 @@
 package bitso
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path"
 	"strings"
 	"testing"
 	"time"
@@ -50,7 +53,7 @@ func TestNewClient(t *testing.T) {
 
 	require.NotNil(t, c, "NewClient should return non-nil client")
 	assert.NotNil(t, c.client, "HTTP client should be initialized")
-	assert.NotNil(t, c.tickets, "Tickets channel should be initialized")
+	assert.Nil(t, c.limiter, "Rate limiting should be disabled by default")
 	assert.Equal(t, "https://bitso.com/api/", c.baseURL, "Default base URL should be set")
 	assert.Equal(t, "v3", c.version, "Default version should be v3")
 	assert.Equal(t, time.Duration(0), c.burstRate, "Default burst rate should be 0")
@@ -226,6 +229,7 @@ func TestTrades(t *testing.T) {
 		trades, err := client.Trades(nil)
 
 		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrInvalidPayload)
 		assert.Nil(t, trades)
 	})
 }
@@ -668,6 +672,139 @@ func TestCancelOrders(t *testing.T) {
 	require.Len(t, cancelled, 2)
 }
 
+func TestLookupOrdersByOriginID(t *testing.T) {
+	server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method)
+		assert.True(t, strings.HasSuffix(r.URL.Path, "/orders"))
+		assert.Equal(t, "my-order-1,my-order-2", r.URL.Query().Get("origin_ids"))
+
+		payload := []map[string]interface{}{
+			{"oid": "order1", "origin_id": "my-order-1", "book": "btc_mxn", "status": "open", "side": "buy",
+				"created_at": "2024-01-15T10:30:00+00:00", "updated_at": "2024-01-15T10:30:00+00:00"},
+			{"oid": "order2", "origin_id": "my-order-2", "book": "btc_mxn", "status": "open", "side": "sell",
+				"created_at": "2024-01-15T10:30:00+00:00", "updated_at": "2024-01-15T10:30:00+00:00"},
+		}
+		w.Write(successResponse(payload))
+	})
+	defer server.Close()
+
+	client.SetAuth("test-key", "test-secret")
+	orders, err := client.LookupOrdersByOriginID([]string{"my-order-1", "my-order-2"})
+
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	assert.Equal(t, "order1", orders[0].OID)
+	assert.Equal(t, "my-order-1", orders[0].OriginID)
+	assert.Equal(t, "my-order-2", orders[1].OriginID)
+}
+
+func TestCancelOrdersByOriginID(t *testing.T) {
+	server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "DELETE", r.Method)
+		assert.True(t, strings.HasSuffix(r.URL.Path, "/orders"))
+		assert.Equal(t, "my-order-1,my-order-2", r.URL.Query().Get("origin_ids"))
+
+		w.Write(successResponse([]string{"my-order-1", "my-order-2"}))
+	})
+	defer server.Close()
+
+	client.SetAuth("test-key", "test-secret")
+	cancelled, err := client.CancelOrdersByOriginID([]string{"my-order-1", "my-order-2"})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"my-order-1", "my-order-2"}, cancelled)
+}
+
+func TestCancelAllOrders(t *testing.T) {
+	server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "DELETE", r.Method)
+		assert.True(t, strings.HasSuffix(r.URL.Path, "/orders/all"))
+
+		w.Write(successResponse([]string{"order1", "order2", "order3"}))
+	})
+	defer server.Close()
+
+	client.SetAuth("test-key", "test-secret")
+	cancelled, err := client.CancelAllOrders()
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"order1", "order2", "order3"}, cancelled)
+}
+
+func TestCancelBookOrders(t *testing.T) {
+	const openOrders = 45
+
+	newServer := func(t *testing.T, failBatch int) (*httptest.Server, *Client, *[][]string) {
+		var batches [][]string
+		server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/open_orders"):
+				assert.Equal(t, "btc_mxn", r.URL.Query().Get("book"))
+
+				payload := []map[string]interface{}{}
+				if r.URL.Query().Get("marker") == "" {
+					for i := 0; i < openOrders; i++ {
+						payload = append(payload, map[string]interface{}{
+							"oid": fmt.Sprintf("order%d", i), "book": "btc_mxn", "status": "open", "side": "buy",
+							"created_at": "2024-01-15T10:30:00+00:00", "updated_at": "2024-01-15T10:30:00+00:00",
+						})
+					}
+				}
+				w.Write(successResponse(payload))
+			case r.Method == "DELETE":
+				oids := strings.Split(path.Base(r.URL.Path), ",")
+				batches = append(batches, oids)
+				if len(batches) == failBatch {
+					w.Write(errorResponse(101, "Unknown error"))
+					return
+				}
+				w.Write(successResponse(oids))
+			default:
+				t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			}
+		})
+		return server, client, &batches
+	}
+
+	t.Run("success", func(t *testing.T) {
+		server, client, batches := newServer(t, 0)
+		defer server.Close()
+
+		client.SetAuth("test-key", "test-secret")
+		cancelled, err := client.CancelBookOrders(NewBook(BTC, MXN))
+
+		require.NoError(t, err)
+		assert.Len(t, cancelled, openOrders)
+		require.Len(t, *batches, 3)
+		assert.Len(t, (*batches)[0], maxCancelBatch)
+		assert.Len(t, (*batches)[2], openOrders-2*maxCancelBatch)
+	})
+
+	t.Run("failed batch", func(t *testing.T) {
+		server, client, batches := newServer(t, 2)
+		defer server.Close()
+
+		client.SetAuth("test-key", "test-secret")
+		cancelled, err := client.CancelBookOrders(NewBook(BTC, MXN))
+
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrUnknown)
+		assert.Len(t, *batches, 3, "remaining batches are still attempted")
+		assert.Len(t, cancelled, openOrders-maxCancelBatch)
+	})
+
+	t.Run("nil book", func(t *testing.T) {
+		server, client, batches := newServer(t, 0)
+		defer server.Close()
+
+		cancelled, err := client.CancelBookOrders(nil)
+
+		assert.ErrorIs(t, err, errMissingBook)
+		assert.Empty(t, cancelled)
+		assert.Empty(t, *batches)
+	})
+}
+
 func TestAPIError(t *testing.T) {
 	server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
 		w.Write(errorResponse(101, "Invalid API key"))
@@ -742,3 +879,82 @@ func TestEndpointURL(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "https://api.bitso.com/api/v3/balance", u.String())
 }
+
+func TestContextCancellation(t *testing.T) {
+	t.Run("cancelled before request", func(t *testing.T) {
+		server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("request should not reach the server")
+		})
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		books, err := client.AvailableBooksContext(ctx)
+
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, books)
+	})
+
+	t.Run("deadline exceeded while waiting for response", func(t *testing.T) {
+		release := make(chan struct{})
+		server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		})
+		defer server.Close()
+		defer close(release)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		client.SetAuth("test-key", "test-secret")
+		oid, err := client.PlaceOrderContext(ctx, &OrderPlacement{
+			Book:  *NewBook(BTC, MXN),
+			Side:  OrderSideBuy,
+			Type:  OrderTypeLimit,
+			Major: ToMonetary(0.1),
+			Price: ToMonetary(500000),
+		})
+
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Empty(t, oid)
+	})
+
+	t.Run("cancelled while waiting for burst-rate ticket", func(t *testing.T) {
+		server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Write(successResponse([]interface{}{}))
+		})
+		defer server.Close()
+
+		client.SetBurstRate(time.Hour)
+		_, err := client.AvailableBooks()
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err = client.AvailableBooksContext(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestCancelOrdersContext(t *testing.T) {
+	server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "DELETE", r.Method)
+		assert.True(t, strings.Contains(r.URL.Path, "/orders/order1,order2"))
+
+		w.Write(successResponse([]string{"order1", "order2"}))
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	client.SetAuth("test-key", "test-secret")
+	cancelled, err := client.CancelOrdersContext(ctx, []string{"order1", "order2"})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"order1", "order2"}, cancelled)
+}
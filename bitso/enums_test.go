@@ -300,6 +300,89 @@ func TestOrderStatus_SQLScan(t *testing.T) {
 	})
 }
 
+// TimeInForce tests
+
+func TestTimeInForce_String(t *testing.T) {
+	tests := []struct {
+		tif      TimeInForce
+		expected string
+	}{
+		{TimeInForceGoodTillCancelled, "goodtillcancelled"},
+		{TimeInForceFillOrKill, "fillorkill"},
+		{TimeInForceImmediateOrCancel, "immediateorcancel"},
+		{TimeInForcePostOnly, "postonly"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.expected, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.tif.String())
+		})
+	}
+}
+
+func TestTimeInForce_StringUnknown(t *testing.T) {
+	assert.Equal(t, "TimeInForce(99)", TimeInForce(99).String())
+	assert.Equal(t, "TimeInForce(0)", TimeInForceNone.String())
+}
+
+func TestTimeInForce_JSONRoundtrip(t *testing.T) {
+	for _, tif := range []TimeInForce{
+		TimeInForceGoodTillCancelled,
+		TimeInForceFillOrKill,
+		TimeInForceImmediateOrCancel,
+		TimeInForcePostOnly,
+	} {
+		t.Run(tif.String(), func(t *testing.T) {
+			data, err := json.Marshal(tif)
+			require.NoError(t, err)
+			assert.Equal(t, `"`+tif.String()+`"`, string(data))
+
+			var decoded TimeInForce
+			require.NoError(t, json.Unmarshal(data, &decoded))
+			assert.Equal(t, tif, decoded)
+		})
+	}
+}
+
+func TestTimeInForce_MarshalJSON_None(t *testing.T) {
+	data, err := json.Marshal(TimeInForceNone)
+	require.NoError(t, err)
+	assert.Equal(t, `""`, string(data))
+}
+
+func TestTimeInForce_UnmarshalJSON_Empty(t *testing.T) {
+	t.Run("empty string", func(t *testing.T) {
+		tif := TimeInForcePostOnly
+		require.NoError(t, json.Unmarshal([]byte(`""`), &tif))
+		assert.Equal(t, TimeInForceNone, tif)
+	})
+
+	t.Run("null", func(t *testing.T) {
+		var tif TimeInForce
+		require.NoError(t, json.Unmarshal([]byte(`null`), &tif))
+		assert.Equal(t, TimeInForceNone, tif)
+	})
+}
+
+func TestTimeInForce_UnmarshalJSON_Invalid(t *testing.T) {
+	var tif TimeInForce
+	err := json.Unmarshal([]byte(`"forever"`), &tif)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported time in force")
+}
+
+func TestTimeInForce_SQL(t *testing.T) {
+	v, err := TimeInForcePostOnly.Value()
+	require.NoError(t, err)
+	assert.Equal(t, "postonly", v)
+
+	var tif TimeInForce
+	require.NoError(t, tif.Scan("fillorkill"))
+	assert.Equal(t, TimeInForceFillOrKill, tif)
+	require.NoError(t, tif.Scan(nil))
+	assert.Error(t, tif.Scan("invalid"))
+}
+
 // Operation tests
 
 func TestOperation_String(t *testing.T) {
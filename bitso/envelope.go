@@ -2,6 +2,10 @@ package bitso
 
 import (
 	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
 )
 
 // Envelope represents a common response envelope from Bitso API.
@@ -16,19 +20,91 @@ type Envelope struct {
 // Error represents an API error
 type Error struct {
 	code    int
+	rawCode string
 	message string
+
+	status int
+	body   []byte
 }
 
 // Error returns the error message.
 func (e Error) Error() string {
+	if e.code == 0 && e.rawCode != "" {
+		return fmt.Sprintf("Error %s: %s", e.rawCode, e.message)
+	}
 	return fmt.Sprintf("Error %v: %s", e.code, e.message)
 }
 
-// Code returns the error code.
+// Code returns the error code, or zero if the code is not numeric.
 func (e Error) Code() int {
 	return e.code
 }
 
-func apiError(code int, message string) error {
-	return &Error{code: code, message: message}
+// RawCode returns the error code as it was sent by the API.
+func (e Error) RawCode() string {
+	return e.rawCode
+}
+
+// Message returns the error message sent by the API.
+func (e Error) Message() string {
+	return e.message
+}
+
+// StatusCode returns the HTTP status of the response that carried the error.
+func (e Error) StatusCode() int {
+	return e.status
+}
+
+// Body returns the raw body of the response that carried the error.
+func (e Error) Body() []byte {
+	return e.body
+}
+
+// Unwrap returns the sentinel error matching the error code (or the HTTP
+// status) if there is one.
+func (e Error) Unwrap() error {
+	return lookupError(e.code, e.status)
+}
+
+// envelopeError creates an error from an unsuccessful response envelope.
+func envelopeError(env *Envelope, status int, body []byte) *Error {
+	code, rawCode := parseErrorCode(env.Error.Code)
+	return &Error{
+		code:    code,
+		rawCode: rawCode,
+		message: env.Error.Message,
+		status:  status,
+		body:    body,
+	}
+}
+
+// httpError creates an error from a response that could not be decoded.
+func httpError(status int, body []byte) *Error {
+	return &Error{
+		message: http.StatusText(status),
+		status:  status,
+		body:    body,
+	}
+}
+
+// parseErrorCode decodes an error code that can be either a JSON number or a
+// string, like "0201".
+func parseErrorCode(v interface{}) (int, string) {
+	switch z := v.(type) {
+	case nil:
+		return 0, ""
+	case float64:
+		if z == math.Trunc(z) && math.Abs(z) <= math.MaxInt32 {
+			return int(z), strconv.Itoa(int(z))
+		}
+		return 0, strconv.FormatFloat(z, 'f', -1, 64)
+	case string:
+		raw := strings.TrimSpace(z)
+		code, err := strconv.Atoi(raw)
+		if err != nil {
+			return 0, raw
+		}
+		return code, raw
+	}
+	return 0, fmt.Sprintf("%v", v)
 }
@@ -0,0 +1,75 @@
+package bitso
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Errors returned by the Bitso API. Use errors.Is to tell whether an API
+// error matches any of them:
+//
+//	if errors.Is(err, bitso.ErrUnknownBook) {
+//		...
+//	}
+//
+// Only the error codes listed in errorCodes are matched. Some of these errors
+// are returned by this package as well, like ErrOrderNotFound from
+// LookupOrder or ErrInsufficientFunds from PaperClient.
+var (
+	ErrUnknown        = errors.New("unknown error")
+	ErrInvalidPayload = errors.New("invalid payload")
+	// ErrInvalidNonce is matched by code 0201, which Bitso returns for
+	// invalid credentials as well.
+	ErrInvalidNonce       = errors.New("invalid nonce")
+	ErrInvalidAPIKey      = errors.New("invalid API key")
+	ErrInvalidSignature   = errors.New("invalid signature")
+	ErrUnknownBook        = errors.New("unknown book")
+	ErrBookDisabled       = errors.New("book is disabled")
+	ErrInvalidPrice       = errors.New("invalid price")
+	ErrInvalidAmount      = errors.New("invalid amount")
+	ErrInvalidOrderSide   = errors.New("invalid order side")
+	ErrInvalidOrderType   = errors.New("invalid order type")
+	ErrInsufficientFunds  = errors.New("insufficient funds")
+	ErrOrderNotFound      = errors.New("no such order")
+	ErrRateLimited        = errors.New("rate limited")
+	ErrServiceUnavailable = errors.New("service unavailable")
+)
+
+// ErrDuplicatedOriginID is returned by PaperClient for an origin_id that is
+// already in use. Bitso's documentation gives no error code for it, so API
+// errors never match it.
+var ErrDuplicatedOriginID = errors.New("duplicated origin_id")
+
+// errorCodes maps Bitso's error codes to their sentinel errors. It only lists
+// codes described in the "Error Codes" section of Bitso's API documentation,
+// any other code is still available from Error.Code.
+var errorCodes = map[int]error{
+	101: ErrUnknown, // Unknown error
+
+	201: ErrInvalidNonce,     // Invalid nonce or invalid credentials
+	202: ErrInvalidAPIKey,    // API key is not active
+	203: ErrInvalidAPIKey,    // Incorrect API key
+	205: ErrInvalidSignature, // Invalid signature
+
+	301: ErrUnknownBook,       // Unknown order book
+	303: ErrInvalidPayload,    // Required field missing
+	304: ErrInvalidPayload,    // Invalid field
+	343: ErrBookDisabled,      // Order book is disabled
+	379: ErrInsufficientFunds, // Insufficient funds
+
+	404: ErrOrderNotFound, // Order not found
+}
+
+// statusErrors maps HTTP status codes to sentinel errors, for responses that
+// do not carry a known error code.
+var statusErrors = map[int]error{
+	http.StatusTooManyRequests:    ErrRateLimited,
+	http.StatusServiceUnavailable: ErrServiceUnavailable,
+}
+
+func lookupError(code int, status int) error {
+	if err, ok := errorCodes[code]; ok {
+		return err
+	}
+	return statusErrors[status]
+}
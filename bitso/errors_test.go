@@ -0,0 +1,133 @@
+package bitso
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseErrorCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      interface{}
+		code    int
+		rawCode string
+	}{
+		{"nil", nil, 0, ""},
+		{"number", float64(101), 101, "101"},
+		{"padded string", "0201", 201, "0201"},
+		{"string with spaces", " 379 ", 379, "379"},
+		{"non-numeric string", "E_NONCE", 0, "E_NONCE"},
+		{"fractional number", float64(1.5), 0, "1.5"},
+		{"unexpected type", true, 0, "true"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			code, rawCode := parseErrorCode(tc.in)
+			assert.Equal(t, tc.code, code)
+			assert.Equal(t, tc.rawCode, rawCode)
+		})
+	}
+}
+
+func TestError_Is(t *testing.T) {
+	tests := []struct {
+		code     int
+		status   int
+		expected error
+	}{
+		{201, http.StatusBadRequest, ErrInvalidNonce},
+		{202, http.StatusUnauthorized, ErrInvalidAPIKey},
+		{205, http.StatusUnauthorized, ErrInvalidSignature},
+		{301, http.StatusBadRequest, ErrUnknownBook},
+		{303, http.StatusBadRequest, ErrInvalidPayload},
+		{343, http.StatusBadRequest, ErrBookDisabled},
+		{379, http.StatusBadRequest, ErrInsufficientFunds},
+		{404, http.StatusNotFound, ErrOrderNotFound},
+		{0, http.StatusTooManyRequests, ErrRateLimited},
+		{0, http.StatusServiceUnavailable, ErrServiceUnavailable},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.expected.Error(), func(t *testing.T) {
+			err := error(&Error{code: tc.code, status: tc.status})
+			assert.ErrorIs(t, err, tc.expected)
+		})
+	}
+
+	t.Run("unknown code", func(t *testing.T) {
+		err := error(&Error{code: 999, status: http.StatusBadRequest})
+		assert.NoError(t, errors.Unwrap(err))
+		assert.NotErrorIs(t, err, ErrUnknown)
+	})
+
+	t.Run("duplicated origin_id", func(t *testing.T) {
+		err := error(&Error{code: 405, status: http.StatusBadRequest})
+		assert.NotErrorIs(t, err, ErrDuplicatedOriginID)
+	})
+}
+
+func TestError_String(t *testing.T) {
+	assert.Equal(t, "Error 379: Insufficient funds", Error{code: 379, rawCode: "0379", message: "Insufficient funds"}.Error())
+	assert.Equal(t, "Error E_NONCE: Bad nonce", Error{rawCode: "E_NONCE", message: "Bad nonce"}.Error())
+}
+
+func TestClientAPIError(t *testing.T) {
+	t.Run("string code", func(t *testing.T) {
+		body := `{"success": false, "error": {"code": "0301", "message": "Unknown OrderBook"}}`
+		server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(body))
+		})
+		defer server.Close()
+
+		client.SetAuth("test-key", "test-secret")
+		_, err := client.PlaceOrder(&OrderPlacement{Book: *NewBook(BTC, MXN)})
+
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrUnknownBook)
+
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, 301, apiErr.Code())
+		assert.Equal(t, "0301", apiErr.RawCode())
+		assert.Equal(t, "Unknown OrderBook", apiErr.Message())
+		assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode())
+		assert.JSONEq(t, body, string(apiErr.Body()))
+	})
+
+	t.Run("non-json error response", func(t *testing.T) {
+		server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte("<html>Too many requests</html>"))
+		})
+		defer server.Close()
+
+		_, err := client.AvailableBooks()
+
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrRateLimited)
+
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, 0, apiErr.Code())
+		assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode())
+		assert.Equal(t, "<html>Too many requests</html>", string(apiErr.Body()))
+	})
+
+	t.Run("lookup missing order", func(t *testing.T) {
+		server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Write(successResponse([]interface{}{}))
+		})
+		defer server.Close()
+
+		client.SetAuth("test-key", "test-secret")
+		_, err := client.LookupOrder("nonexistent")
+
+		assert.ErrorIs(t, err, ErrOrderNotFound)
+	})
+}
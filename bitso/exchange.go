@@ -0,0 +1,54 @@
+package bitso
+
+import (
+	"context"
+	"net/url"
+)
+
+// MarketData is the set of public market data endpoints.
+type MarketData interface {
+	AvailableBooksContext(ctx context.Context) ([]ExchangeOrderBook, error)
+	TickersContext(ctx context.Context) ([]Ticker, error)
+	TickerContext(ctx context.Context, book *Book) (*Ticker, error)
+	TradesContext(ctx context.Context, params url.Values) ([]Trade, error)
+	OrderBookContext(ctx context.Context, params url.Values) (*OrderBook, error)
+}
+
+// Account is the set of private endpoints that report on the user's funds
+// and past activity.
+type Account interface {
+	BalancesContext(ctx context.Context, params url.Values) ([]Balance, error)
+	FeesContext(ctx context.Context, params url.Values) (*CustomerFees, error)
+	LedgerContext(ctx context.Context, params url.Values) ([]Transaction, error)
+	LedgerByOperationContext(ctx context.Context, op Operation, params url.Values) ([]Transaction, error)
+	FundingsContext(ctx context.Context, params url.Values) ([]Funding, error)
+	WithdrawalsContext(ctx context.Context, params url.Values) ([]Withdrawal, error)
+	MyTradesContext(ctx context.Context, params url.Values) ([]UserTrade, error)
+}
+
+// Trading is the set of private endpoints that place, look up and cancel
+// orders.
+type Trading interface {
+	PlaceOrderContext(ctx context.Context, order *OrderPlacement) (string, error)
+	MyOpenOrdersContext(ctx context.Context, params url.Values) ([]UserOrder, error)
+	OrderTradesContext(ctx context.Context, oid string, params url.Values) ([]UserOrderTrade, error)
+	LookupOrderContext(ctx context.Context, oid string) (*UserOrder, error)
+	LookupOrdersContext(ctx context.Context, oids []string) ([]UserOrder, error)
+	LookupOrdersByOriginIDContext(ctx context.Context, originIDs []string) ([]UserOrder, error)
+	CancelOrderContext(ctx context.Context, oid string) ([]string, error)
+	CancelOrdersContext(ctx context.Context, oids []string) ([]string, error)
+	CancelOrdersByOriginIDContext(ctx context.Context, originIDs []string) ([]string, error)
+	CancelAllOrdersContext(ctx context.Context) ([]string, error)
+	CancelBookOrdersContext(ctx context.Context, book *Book) ([]string, error)
+}
+
+// Exchange is everything Client can do. Code that depends on Exchange, or
+// on one of its parts, can be given a fake or simulated implementation
+// instead of a Client.
+type Exchange interface {
+	MarketData
+	Account
+	Trading
+}
+
+var _ Exchange = (*Client)(nil)
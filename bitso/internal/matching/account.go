@@ -0,0 +1,477 @@
+package matching
+
+import (
+	"slices"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// A Placement describes a new order of the user. Amounts and prices are
+// decimal strings, empty when not given.
+type Placement struct {
+	Side        Side
+	Type        Type
+	TimeInForce TimeInForce
+	OriginID    string
+
+	Major string
+	Minor string
+	Price string
+	Stop  string
+}
+
+// A Trade is a fill of one of the user's orders.
+type Trade struct {
+	TID   uint64
+	Order *Order
+
+	// Major and Minor are the balance changes, before fees.
+	Major decimal.Decimal
+	Minor decimal.Decimal
+
+	Fee         decimal.Decimal
+	FeeCurrency string
+
+	Price     decimal.Decimal
+	CreatedAt time.Time
+}
+
+// A Fill is a match between two orders of a book.
+type Fill struct {
+	TID       uint64
+	Book      *Book
+	MakerID   string
+	MakerSide Side
+	Amount    decimal.Decimal
+	Price     decimal.Decimal
+	CreatedAt time.Time
+}
+
+// An Account holds the balances, orders and trades of the user of a simulated
+// exchange. It's not safe for concurrent use.
+type Account struct {
+	Balances map[string]decimal.Decimal
+
+	// Orders and Trades are kept oldest first.
+	Orders []*Order
+	Trades []Trade
+
+	// RestOnBook tells whether the user's limit orders rest on their book,
+	// where other orders can take them. Otherwise they're only filled by
+	// FillResting.
+	RestOnBook bool
+
+	// NewOID and NewTID return the IDs of new orders and trades.
+	NewOID func() string
+	NewTID func() uint64
+
+	// OnFill, if set, is called after every match between two orders.
+	OnFill func(Fill)
+}
+
+// Credit adds amount, which may be negative, to the balance of currency.
+func (a *Account) Credit(currency string, amount decimal.Decimal) {
+	a.Balances[currency] = a.Balances[currency].Add(amount)
+}
+
+// Locked returns the amount of currency reserved by open limit orders.
+func (a *Account) Locked(currency string) decimal.Decimal {
+	total := decimal.Zero
+	for _, o := range a.Orders {
+		if !o.IsOpen() || o.Type != Limit {
+			continue
+		}
+		switch {
+		case o.Side == Buy && o.Book.Minor == currency:
+			total = total.Add(o.Price.Mul(o.Unfilled))
+		case o.Side == Sell && o.Book.Major == currency:
+			total = total.Add(o.Unfilled)
+		}
+	}
+	return total
+}
+
+// Available returns the balance of currency not reserved by open orders.
+func (a *Account) Available(currency string) decimal.Decimal {
+	return a.Balances[currency].Sub(a.Locked(currency))
+}
+
+// Affordable returns how much major can be bought or sold on b at price with
+// the available balance.
+func (a *Account) Affordable(b *Book, side Side, price decimal.Decimal) decimal.Decimal {
+	if side == Buy {
+		return Div(a.Available(b.Minor), price)
+	}
+	return a.Available(b.Major)
+}
+
+// Lookup returns the order with the given ID.
+func (a *Account) Lookup(oid string) *Order {
+	for _, o := range a.Orders {
+		if o.ID == oid {
+			return o
+		}
+	}
+	return nil
+}
+
+// Select returns the orders with the given IDs, or origin IDs. Unknown IDs
+// are ignored.
+func (a *Account) Select(ids []string, byOriginID bool) []*Order {
+	var res []*Order
+	for _, id := range ids {
+		for _, o := range a.Orders {
+			if (byOriginID && id != "" && o.OriginID == id) || (!byOriginID && o.ID == id) {
+				res = append(res, o)
+			}
+		}
+	}
+	return res
+}
+
+// Cancel cancels the open orders among orders and returns their IDs, or
+// their origin IDs.
+func (a *Account) Cancel(orders []*Order, byOriginID bool, now time.Time) []string {
+	cancelled := []string{}
+	for _, o := range orders {
+		if !o.IsOpen() {
+			continue
+		}
+		o.Book.Remove(o)
+		o.Status = Cancelled
+		o.UpdatedAt = now
+
+		if byOriginID {
+			cancelled = append(cancelled, o.OriginID)
+		} else {
+			cancelled = append(cancelled, o.ID)
+		}
+	}
+	return cancelled
+}
+
+// Place validates a placement on b, adds the order to the account and
+// executes it.
+func (a *Account) Place(b *Book, p Placement, now time.Time) (*Order, error) {
+	o, err := a.newOrder(b, p, now)
+	if err != nil {
+		return nil, err
+	}
+	a.Orders = append(a.Orders, o)
+	a.execute(o, now)
+	return o, nil
+}
+
+// newOrder validates a placement and turns it into an order.
+func (a *Account) newOrder(b *Book, p Placement, now time.Time) (*Order, error) {
+	parse := func(v string) (decimal.Decimal, error) {
+		if v == "" {
+			return decimal.Zero, nil
+		}
+		d, err := decimal.NewFromString(v)
+		if err != nil || d.IsNegative() {
+			return decimal.Zero, reject(ReasonInvalidAmount, "%q", v)
+		}
+		return d, nil
+	}
+
+	if p.Side != Buy && p.Side != Sell {
+		return nil, reject(ReasonInvalidSide, "%d", p.Side)
+	}
+	if p.Type != Market && p.Type != Limit {
+		return nil, reject(ReasonInvalidType, "%d", p.Type)
+	}
+	if p.Stop != "" {
+		return nil, reject(ReasonInvalidType, "stop orders are not supported")
+	}
+	if p.OriginID != "" {
+		for _, o := range a.Orders {
+			if o.IsOpen() && o.OriginID == p.OriginID {
+				return nil, reject(ReasonDuplicatedOriginID, "%q", p.OriginID)
+			}
+		}
+	}
+
+	major, err := parse(p.Major)
+	if err != nil {
+		return nil, err
+	}
+	minor, err := parse(p.Minor)
+	if err != nil {
+		return nil, err
+	}
+	price, err := parse(p.Price)
+	if err != nil {
+		return nil, err
+	}
+	if major.IsPositive() == minor.IsPositive() {
+		return nil, reject(ReasonInvalidAmount, "either major or minor must be given")
+	}
+
+	o := &Order{
+		User:        true,
+		Book:        b,
+		Side:        p.Side,
+		Type:        p.Type,
+		TimeInForce: p.TimeInForce,
+		OriginID:    p.OriginID,
+		Status:      Open,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	switch o.Type {
+	case Limit:
+		if !price.IsPositive() {
+			return nil, reject(ReasonInvalidPrice, "%q", p.Price)
+		}
+		if major.IsZero() {
+			major = Div(minor, price)
+		}
+		if o.TimeInForce == 0 {
+			o.TimeInForce = GoodTillCancelled
+		}
+		o.Price = price
+
+		needed, currency := major, b.Major
+		if o.Side == Buy {
+			needed, currency = price.Mul(major), b.Minor
+		}
+		if a.Available(currency).LessThan(needed) {
+			return nil, reject(ReasonInsufficientFunds, "%s", currency)
+		}
+
+	case Market:
+		// Market orders take what they can right away.
+		o.TimeInForce = 0
+		if minor.IsPositive() {
+			o.ByMinor = true
+			o.Budget, o.Value = minor, minor
+		}
+		if o.Side == Buy && o.ByMinor && a.Available(b.Minor).LessThan(minor) {
+			return nil, reject(ReasonInsufficientFunds, "%s", b.Minor)
+		}
+		if o.Side == Sell && !o.ByMinor && a.Available(b.Major).LessThan(major) {
+			return nil, reject(ReasonInsufficientFunds, "%s", b.Major)
+		}
+	}
+
+	// The amount of orders placed by minor amount is known after matching.
+	if !o.ByMinor {
+		if err := b.checkLimits(major, price, o.Type); err != nil {
+			return nil, err
+		}
+		o.Amount, o.Unfilled = major, major
+	}
+
+	o.ID = a.NewOID()
+	return o, nil
+}
+
+// execute takes liquidity for a new order and rests whatever is left,
+// honoring its time in force. Market orders wait for their book to be
+// synced.
+func (a *Account) execute(o *Order, now time.Time) {
+	b := o.Book
+
+	if o.Type == Market {
+		if b.Synced {
+			a.Take(o, now)
+			finish(o, now)
+		}
+		return
+	}
+
+	switch o.TimeInForce {
+	case PostOnly:
+		if b.Fillable(o).IsPositive() {
+			o.Status = Cancelled
+			return
+		}
+	case FillOrKill:
+		if b.Fillable(o).LessThan(o.Unfilled) {
+			o.Status = Cancelled
+			return
+		}
+	}
+
+	a.Take(o, now)
+
+	if !o.Pending() {
+		return
+	}
+	if o.TimeInForce == ImmediateOrCancel || o.TimeInForce == FillOrKill {
+		o.Status = Cancelled
+		return
+	}
+	if a.RestOnBook {
+		b.Rest(o)
+	}
+}
+
+// Take fills o against the opposite side of its book until it's filled, it
+// no longer crosses or the user runs out of funds for it.
+func (a *Account) Take(o *Order, now time.Time) {
+	b := o.Book
+
+	for o.Pending() {
+		opposite := b.Opposite(o.Side)
+		if len(opposite) == 0 {
+			return
+		}
+		maker := opposite[0]
+		if !o.Crosses(maker.Price) {
+			return
+		}
+
+		qty := maker.Unfilled
+		if o.ByMinor {
+			qty = decimal.Min(qty, Div(o.Budget, maker.Price))
+		} else {
+			qty = decimal.Min(qty, o.Unfilled)
+		}
+		if o.User && o.Type == Market {
+			qty = decimal.Min(qty, a.Affordable(b, o.Side, maker.Price))
+		}
+		if !qty.IsPositive() {
+			return
+		}
+
+		a.match(maker, o, qty, now)
+
+		if !maker.Pending() {
+			b.Remove(maker)
+		}
+	}
+}
+
+// Refresh lets the open orders of b take from it after it changed, and
+// closes the market orders that were waiting for it to be synced.
+func (a *Account) Refresh(b *Book, now time.Time) {
+	b.Synced = true
+
+	for _, o := range a.Orders {
+		if o.Book != b || !o.IsOpen() {
+			continue
+		}
+		a.Take(o, now)
+		if o.Type == Market {
+			finish(o, now)
+		}
+	}
+}
+
+// FillResting fills the user's limit orders of b that were hit by a trade
+// made elsewhere, in price-time priority and up to the traded amount. Only
+// orders on makerSide, the side of the order that rested on the book, that a
+// trade at price went through are filled, at their own price.
+func (a *Account) FillResting(b *Book, makerSide Side, price, amount decimal.Decimal, now time.Time) {
+	var resting []*Order
+	for _, o := range a.Orders {
+		if o.Book == b && o.Side == makerSide && o.IsOpen() && o.Type == Limit && o.Crosses(price) {
+			resting = append(resting, o)
+		}
+	}
+	slices.SortStableFunc(resting, func(x, y *Order) int {
+		if makerSide == Buy {
+			return y.Price.Cmp(x.Price)
+		}
+		return x.Price.Cmp(y.Price)
+	})
+
+	for _, o := range resting {
+		if !amount.IsPositive() {
+			return
+		}
+		qty := decimal.Min(amount, o.Unfilled)
+		a.fill(o, qty, o.Price, b.Maker, a.NewTID(), now)
+		amount = amount.Sub(qty)
+	}
+}
+
+// match fills a taker against a maker at the price of the maker.
+func (a *Account) match(maker, taker *Order, qty decimal.Decimal, now time.Time) {
+	tid := a.NewTID()
+	b := maker.Book
+	a.fill(maker, qty, maker.Price, b.Maker, tid, now)
+	a.fill(taker, qty, maker.Price, b.Taker, tid, now)
+
+	b.Sequence++
+	if a.OnFill != nil {
+		a.OnFill(Fill{
+			TID:       tid,
+			Book:      b,
+			MakerID:   maker.ID,
+			MakerSide: maker.Side,
+			Amount:    qty,
+			Price:     maker.Price,
+			CreatedAt: now,
+		})
+	}
+}
+
+// fill updates o after qty of it traded at price and, for orders of the
+// user, settles the trade paying the given fee rate.
+func (a *Account) fill(o *Order, qty, price, rate decimal.Decimal, tid uint64, now time.Time) {
+	value := qty.Mul(price)
+
+	if o.ByMinor {
+		o.Amount = o.Amount.Add(qty)
+		o.Budget = o.Budget.Sub(value)
+	} else {
+		o.Unfilled = o.Unfilled.Sub(qty)
+	}
+	o.UpdatedAt = now
+	if o.Pending() {
+		o.Status = PartialFill
+	} else {
+		o.Status = Completed
+	}
+
+	if !o.User {
+		return
+	}
+
+	b := o.Book
+	major, minor := qty, value.Neg()
+	feeCurrency := b.Major
+	fee := qty.Mul(rate)
+	if o.Side == Sell {
+		major, minor = qty.Neg(), value
+		feeCurrency = b.Minor
+		fee = value.Mul(rate)
+	}
+
+	a.Credit(b.Major, major)
+	a.Credit(b.Minor, minor)
+	a.Credit(feeCurrency, fee.Neg())
+
+	a.Trades = append(a.Trades, Trade{
+		TID:         tid,
+		Order:       o,
+		Major:       major,
+		Minor:       minor,
+		Fee:         fee,
+		FeeCurrency: feeCurrency,
+		Price:       price,
+		CreatedAt:   now,
+	})
+}
+
+// finish closes a market order after it took what it could.
+func finish(o *Order, now time.Time) {
+	filled := o.Amount.Sub(o.Unfilled)
+	if o.ByMinor {
+		filled = o.Amount
+		o.Budget = decimal.Zero
+	}
+	o.Amount, o.Unfilled = filled, decimal.Zero
+	o.UpdatedAt = now
+
+	if filled.IsPositive() {
+		o.Status = Completed
+	} else {
+		o.Status = Cancelled
+	}
+}
@@ -0,0 +1,119 @@
+package matching
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAccount() (*Account, *Book) {
+	var oid, tid uint64
+	a := &Account{
+		Balances:   map[string]decimal.Decimal{"btc": decimal.NewFromInt(1), "mxn": decimal.NewFromInt(1000000)},
+		RestOnBook: true,
+		NewOID: func() string {
+			oid++
+			return "o" + decimal.NewFromInt(int64(oid)).String()
+		},
+		NewTID: func() uint64 {
+			tid++
+			return tid
+		},
+	}
+	b := NewBook("btc", "mxn", "0.5", "0.65", Limits{MinAmount: "0.0001"})
+	b.Synced = true
+	return a, b
+}
+
+func restOrder(b *Book, side Side, price, amount string) {
+	b.Rest(&Order{
+		Book:     b,
+		Side:     side,
+		Type:     Limit,
+		Price:    decimal.RequireFromString(price),
+		Unfilled: decimal.RequireFromString(amount),
+		Status:   Open,
+	})
+}
+
+func TestAccount_Place(t *testing.T) {
+	a, b := newTestAccount()
+	restOrder(b, Sell, "501000", "0.1")
+	restOrder(b, Sell, "500000", "0.1")
+
+	var fills []Fill
+	a.OnFill = func(fill Fill) {
+		fills = append(fills, fill)
+	}
+
+	o, err := a.Place(b, Placement{Side: Buy, Type: Market, Minor: "75050"}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, Completed, o.Status)
+	assert.Equal(t, "0.15", o.Amount.String())
+	assert.Equal(t, "75050", o.Value.String())
+
+	require.Len(t, fills, 2)
+	assert.Equal(t, Sell, fills[0].MakerSide)
+	assert.Equal(t, "500000", fills[0].Price.String())
+	assert.Equal(t, "0.05", fills[1].Amount.String())
+
+	require.Len(t, a.Trades, 2)
+	assert.Equal(t, "0.000325", a.Trades[1].Fee.String())
+	assert.Equal(t, "btc", a.Trades[1].FeeCurrency)
+	require.Len(t, b.Asks, 1)
+	assert.Equal(t, "0.05", b.Asks[0].Unfilled.String())
+
+	_, err = a.Place(b, Placement{Side: Sell, Type: Limit, Price: "500000", Major: "2"}, time.Now())
+	var rejection *Rejection
+	require.ErrorAs(t, err, &rejection)
+	assert.Equal(t, ReasonInsufficientFunds, rejection.Reason)
+
+	_, err = a.Place(b, Placement{Side: Sell, Type: Limit, Price: "500000", Major: "0.00001"}, time.Now())
+	require.ErrorAs(t, err, &rejection)
+	assert.Equal(t, ReasonInvalidAmount, rejection.Reason)
+}
+
+func TestAccount_FillResting(t *testing.T) {
+	a, b := newTestAccount()
+	a.RestOnBook = false
+
+	buy, err := a.Place(b, Placement{Side: Buy, Type: Limit, Price: "499000", Major: "0.1"}, time.Now())
+	require.NoError(t, err)
+	sell, err := a.Place(b, Placement{Side: Sell, Type: Limit, Price: "501000", Major: "0.1"}, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, b.Bids)
+
+	// A trade that hit the asks doesn't fill the bids, even though its
+	// price went through them.
+	a.FillResting(b, Sell, decimal.RequireFromString("502000"), decimal.RequireFromString("0.04"), time.Now())
+	assert.Equal(t, "0.1", buy.Unfilled.String())
+	assert.Equal(t, "0.06", sell.Unfilled.String())
+	assert.Equal(t, PartialFill, sell.Status)
+
+	a.FillResting(b, Buy, decimal.RequireFromString("498000"), decimal.RequireFromString("1"), time.Now())
+	assert.Equal(t, Completed, buy.Status)
+	assert.Equal(t, "499000", a.Trades[1].Price.String())
+}
+
+func TestPage(t *testing.T) {
+	items := []string{"a", "b", "c"}
+	id := func(s string) string { return s }
+
+	page, err := Page(items, id, url.Values{"limit": {"2"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "b"}, page)
+	assert.Equal(t, []string{"a", "b", "c"}, items)
+
+	page, err = Page(items, id, url.Values{"sort": {"asc"}, "marker": {"a"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, page)
+
+	_, err = Page(items, id, url.Values{"marker": {"x"}})
+	var rejection *Rejection
+	require.ErrorAs(t, err, &rejection)
+	assert.Equal(t, ReasonInvalidPayload, rejection.Reason)
+}
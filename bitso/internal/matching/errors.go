@@ -0,0 +1,46 @@
+package matching
+
+import "fmt"
+
+// Reason tells why a request was rejected.
+type Reason uint8
+
+// List of reasons.
+const (
+	ReasonInvalidPayload Reason = iota + 1
+	ReasonInvalidSide
+	ReasonInvalidType
+	ReasonInvalidAmount
+	ReasonInvalidPrice
+	ReasonDuplicatedOriginID
+	ReasonInsufficientFunds
+)
+
+var reasonNames = map[Reason]string{
+	ReasonInvalidPayload:     "invalid payload",
+	ReasonInvalidSide:        "invalid order side",
+	ReasonInvalidType:        "invalid order type",
+	ReasonInvalidAmount:      "invalid amount",
+	ReasonInvalidPrice:       "invalid price",
+	ReasonDuplicatedOriginID: "duplicated origin ID",
+	ReasonInsufficientFunds:  "insufficient funds",
+}
+
+func (r Reason) String() string {
+	return reasonNames[r]
+}
+
+// A Rejection is returned when a request is not valid. Callers map its reason
+// to the errors of the exchange they simulate.
+type Rejection struct {
+	Reason Reason
+	Detail string
+}
+
+func reject(reason Reason, format string, args ...any) *Rejection {
+	return &Rejection{Reason: reason, Detail: fmt.Sprintf(format, args...)}
+}
+
+func (r *Rejection) Error() string {
+	return r.Reason.String() + ": " + r.Detail
+}
@@ -0,0 +1,271 @@
+// Package matching implements the order handling shared by the simulated
+// exchanges of this module: validation of new orders, balance locking,
+// execution honoring time in force, fills and the pagination of listings.
+//
+// It doesn't depend on the bitso package so that package can use it, callers
+// convert from and to bitso's types.
+package matching
+
+import (
+	"slices"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// divPrecision is the number of decimal places kept when dividing amounts.
+const divPrecision = 8
+
+// The values of the enums below match the ones of the bitso package, so
+// callers can convert between them.
+
+// Side of an order.
+type Side uint8
+
+// List of sides.
+const (
+	Buy  Side = 1
+	Sell Side = 2
+)
+
+// Type of an order.
+type Type uint8
+
+// List of order types.
+const (
+	Market Type = 1
+	Limit  Type = 2
+)
+
+// TimeInForce tells how long a limit order remains active.
+type TimeInForce uint8
+
+// List of time in force policies.
+const (
+	GoodTillCancelled TimeInForce = 1
+	FillOrKill        TimeInForce = 2
+	ImmediateOrCancel TimeInForce = 3
+	PostOnly          TimeInForce = 4
+)
+
+// Status of an order.
+type Status uint8
+
+// List of order statuses.
+const (
+	Open        Status = 1
+	PartialFill Status = 3
+	Cancelled   Status = 4
+	Completed   Status = 5
+)
+
+// Limits holds the placement limits of a book, as decimal strings. Empty or
+// non-positive limits are not enforced.
+type Limits struct {
+	MinAmount, MaxAmount string
+	MinPrice, MaxPrice   string
+	MinValue, MaxValue   string
+}
+
+// A Book holds the settings of a book and the orders resting on it.
+type Book struct {
+	Major, Minor string
+
+	// Fee rates, as fractions.
+	Maker decimal.Decimal
+	Taker decimal.Decimal
+
+	limits struct {
+		minAmount, maxAmount decimal.Decimal
+		minPrice, maxPrice   decimal.Decimal
+		minValue, maxValue   decimal.Decimal
+	}
+
+	// Synced is false while the contents of the book are unknown, market
+	// orders wait until it's set.
+	Synced bool
+
+	// Resting orders in priority order: best price first, then oldest first.
+	Bids []*Order
+	Asks []*Order
+
+	// Sequence is increased on every change of the resting orders.
+	Sequence uint64
+}
+
+// NewBook creates an empty book. The fees are percentages, as given by
+// Bitso.
+func NewBook(major, minor string, makerFee, takerFee string, limits Limits) *Book {
+	percent := decimal.NewFromInt(100)
+	maker, _ := decimal.NewFromString(makerFee)
+	taker, _ := decimal.NewFromString(takerFee)
+
+	b := &Book{
+		Major: major,
+		Minor: minor,
+		Maker: maker.Div(percent),
+		Taker: taker.Div(percent),
+	}
+	for _, l := range []struct {
+		dst *decimal.Decimal
+		src string
+	}{
+		{&b.limits.minAmount, limits.MinAmount},
+		{&b.limits.maxAmount, limits.MaxAmount},
+		{&b.limits.minPrice, limits.MinPrice},
+		{&b.limits.maxPrice, limits.MaxPrice},
+		{&b.limits.minValue, limits.MinValue},
+		{&b.limits.maxValue, limits.MaxValue},
+	} {
+		*l.dst, _ = decimal.NewFromString(l.src)
+	}
+	return b
+}
+
+func (b *Book) side(side Side) *[]*Order {
+	if side == Buy {
+		return &b.Bids
+	}
+	return &b.Asks
+}
+
+// Opposite returns the orders an order on the given side can take, best
+// first.
+func (b *Book) Opposite(side Side) []*Order {
+	if side == Buy {
+		return b.Asks
+	}
+	return b.Bids
+}
+
+// Rest adds o to its side of the book keeping price-time priority.
+func (b *Book) Rest(o *Order) {
+	orders := b.side(o.Side)
+	i := slices.IndexFunc(*orders, func(other *Order) bool {
+		if o.Side == Buy {
+			return o.Price.GreaterThan(other.Price)
+		}
+		return o.Price.LessThan(other.Price)
+	})
+	if i < 0 {
+		i = len(*orders)
+	}
+	*orders = slices.Insert(*orders, i, o)
+	b.Sequence++
+}
+
+// Remove takes o out of the book, it tells whether o was resting.
+func (b *Book) Remove(o *Order) bool {
+	orders := b.side(o.Side)
+	i := slices.Index(*orders, o)
+	if i < 0 {
+		return false
+	}
+	*orders = slices.Delete(*orders, i, i+1)
+	b.Sequence++
+	return true
+}
+
+// Find returns the order resting on the book with the given ID.
+func (b *Book) Find(id string) *Order {
+	for _, orders := range [][]*Order{b.Bids, b.Asks} {
+		for _, o := range orders {
+			if o.ID == id {
+				return o
+			}
+		}
+	}
+	return nil
+}
+
+// Fillable returns how much of the book o could take right now.
+func (b *Book) Fillable(o *Order) decimal.Decimal {
+	total := decimal.Zero
+	for _, maker := range b.Opposite(o.Side) {
+		if !o.Crosses(maker.Price) {
+			break
+		}
+		total = total.Add(maker.Unfilled)
+	}
+	return total
+}
+
+// checkLimits verifies the placement limits of the book.
+func (b *Book) checkLimits(amount, price decimal.Decimal, kind Type) error {
+	outOfRange := func(v, lo, hi decimal.Decimal) bool {
+		return (lo.IsPositive() && v.LessThan(lo)) || (hi.IsPositive() && v.GreaterThan(hi))
+	}
+
+	l := b.limits
+	if !amount.IsPositive() || outOfRange(amount, l.minAmount, l.maxAmount) {
+		return reject(ReasonInvalidAmount, "amount %v out of range", amount)
+	}
+	if kind != Limit {
+		return nil
+	}
+	if outOfRange(price, l.minPrice, l.maxPrice) {
+		return reject(ReasonInvalidPrice, "price %v out of range", price)
+	}
+	if value := amount.Mul(price); outOfRange(value, l.minValue, l.maxValue) {
+		return reject(ReasonInvalidAmount, "value %v out of range", value)
+	}
+	return nil
+}
+
+// An Order is either one of the user's orders or an order of someone else
+// resting on a book.
+type Order struct {
+	ID       string
+	OriginID string
+	// User tells whether the order belongs to the account.
+	User bool
+
+	Book        *Book
+	Side        Side
+	Type        Type
+	TimeInForce TimeInForce
+
+	// Price is zero for market orders.
+	Price decimal.Decimal
+	// Amount and Unfilled are in major. Market orders placed by minor amount
+	// spend Budget instead, their Amount grows as they're filled.
+	Amount   decimal.Decimal
+	Unfilled decimal.Decimal
+	Budget   decimal.Decimal
+	ByMinor  bool
+	// Value is the minor amount of orders placed by minor amount.
+	Value decimal.Decimal
+
+	Status    Status
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// IsOpen tells whether o can still be filled.
+func (o *Order) IsOpen() bool {
+	return o.Status == Open || o.Status == PartialFill
+}
+
+// Pending tells whether o still wants to trade.
+func (o *Order) Pending() bool {
+	if o.ByMinor {
+		return o.Budget.IsPositive()
+	}
+	return o.Unfilled.IsPositive()
+}
+
+// Crosses tells whether o would trade at price.
+func (o *Order) Crosses(price decimal.Decimal) bool {
+	if o.Type == Market {
+		return true
+	}
+	if o.Side == Buy {
+		return o.Price.GreaterThanOrEqual(price)
+	}
+	return o.Price.LessThanOrEqual(price)
+}
+
+// Div divides a by b rounding towards zero.
+func Div(a, b decimal.Decimal) decimal.Decimal {
+	return a.DivRound(b, divPrecision+2).Truncate(divPrecision)
+}
@@ -0,0 +1,50 @@
+package matching
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+// Page sizes of listings, as on Bitso.
+const (
+	PageSize    = 25
+	MaxPageSize = 100
+)
+
+// Page returns a page of items, which must be sorted oldest first, following
+// Bitso's "marker", "sort" and "limit" parameters.
+func Page[T any](items []T, id func(T) string, params url.Values) ([]T, error) {
+	limit := PageSize
+	if z := params.Get("limit"); z != "" {
+		n, err := strconv.Atoi(z)
+		if err != nil || n < 1 {
+			return nil, reject(ReasonInvalidPayload, "limit %q", z)
+		}
+		limit = min(n, MaxPageSize)
+	}
+
+	ordered := slices.Clone(items)
+	switch z := params.Get("sort"); z {
+	case "", "desc":
+		slices.Reverse(ordered)
+	case "asc":
+	default:
+		return nil, reject(ReasonInvalidPayload, "sort %q", z)
+	}
+
+	if marker := params.Get("marker"); marker != "" {
+		i := slices.IndexFunc(ordered, func(item T) bool {
+			return id(item) == marker
+		})
+		if i < 0 {
+			return nil, reject(ReasonInvalidPayload, "marker %q", marker)
+		}
+		ordered = ordered[i+1:]
+	}
+
+	if len(ordered) > limit {
+		ordered = ordered[:limit]
+	}
+	return ordered, nil
+}
@@ -0,0 +1,405 @@
+package bitso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// defaultResyncDelay is how long a LocalOrderBook waits before requesting a
+// new snapshot after a failed attempt.
+const defaultResyncDelay = time.Second
+
+var (
+	errSequenceGap     = errors.New("sequence gap")
+	errInvalidSnapshot = errors.New("invalid order book snapshot")
+)
+
+// PriceLevel represents all the orders resting at the same price.
+type PriceLevel struct {
+	Price  Monetary
+	Amount Monetary
+	Orders int
+}
+
+type localOrder struct {
+	price  decimal.Decimal
+	amount decimal.Decimal
+}
+
+type localLevel struct {
+	price  decimal.Decimal
+	amount decimal.Decimal
+	orders int
+}
+
+// bookSide holds the orders on one side of a LocalOrderBook, along with
+// their price levels sorted best first so the top of the book is cheap to
+// read.
+type bookSide struct {
+	descending bool
+	orders     map[string]localOrder
+	levels     []*localLevel
+}
+
+func newBookSide(descending bool) *bookSide {
+	return &bookSide{
+		descending: descending,
+		orders:     map[string]localOrder{},
+	}
+}
+
+// find returns the position of the level at the given price, or where it
+// would be inserted.
+func (s *bookSide) find(price decimal.Decimal) (int, bool) {
+	return slices.BinarySearchFunc(s.levels, price, func(l *localLevel, price decimal.Decimal) int {
+		if s.descending {
+			return price.Cmp(l.price)
+		}
+		return l.price.Cmp(price)
+	})
+}
+
+func (s *bookSide) set(oid string, o localOrder) {
+	s.remove(oid)
+	s.orders[oid] = o
+
+	i, ok := s.find(o.price)
+	if !ok {
+		s.levels = slices.Insert(s.levels, i, &localLevel{price: o.price})
+	}
+	l := s.levels[i]
+	l.amount = l.amount.Add(o.amount)
+	l.orders++
+}
+
+func (s *bookSide) remove(oid string) {
+	o, ok := s.orders[oid]
+	if !ok {
+		return
+	}
+	delete(s.orders, oid)
+
+	i, ok := s.find(o.price)
+	if !ok {
+		return
+	}
+	l := s.levels[i]
+	l.amount = l.amount.Sub(o.amount)
+	l.orders--
+	if l.orders == 0 {
+		s.levels = slices.Delete(s.levels, i, i+1)
+	}
+}
+
+// depth returns up to n levels, all of them if n is not positive.
+func (s *bookSide) depth(n int) []PriceLevel {
+	levels := s.levels
+	if n > 0 && len(levels) > n {
+		levels = levels[:n]
+	}
+
+	res := make([]PriceLevel, 0, len(levels))
+	for _, l := range levels {
+		res = append(res, PriceLevel{
+			Price:  Monetary(l.price.String()),
+			Amount: Monetary(l.amount.String()),
+			Orders: l.orders,
+		})
+	}
+	return res
+}
+
+type snapshotResult struct {
+	orderBook *OrderBook
+	err       error
+}
+
+// A LocalOrderBook keeps an up to date copy of a book, built from an
+// unaggregated REST snapshot and the messages of the "diff-orders" channel.
+//
+// Diffs are applied in sequence order. When a message is missing the book is
+// marked as out of sync and a new snapshot is requested automatically.
+type LocalOrderBook struct {
+	client *Client
+	book   Book
+
+	bids *bookSide
+	asks *bookSide
+
+	sequence uint64
+	synced   bool
+
+	updates chan struct{}
+
+	mu sync.RWMutex
+}
+
+// NewLocalOrderBook creates an empty local order book for the given book. The
+// client is used to fetch snapshots, call Run to start tracking the book.
+func NewLocalOrderBook(client *Client, book Book) *LocalOrderBook {
+	return &LocalOrderBook{
+		client:  client,
+		book:    book,
+		bids:    newBookSide(true),
+		asks:    newBookSide(false),
+		updates: make(chan struct{}, 1),
+	}
+}
+
+// Book returns the book being tracked.
+func (b *LocalOrderBook) Book() Book {
+	return b.book
+}
+
+// Updates returns a channel that receives a value after the book changes.
+// Notifications are coalesced: a single value may stand for many changes.
+func (b *LocalOrderBook) Updates() <-chan struct{} {
+	return b.updates
+}
+
+// Synced tells whether the book is consistent with the exchange. It is false
+// before the first snapshot is loaded and while recovering from a gap.
+func (b *LocalOrderBook) Synced() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.synced
+}
+
+// Sequence returns the sequence number of the last change applied to the
+// book.
+func (b *LocalOrderBook) Sequence() uint64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.sequence
+}
+
+// BestBid returns the highest price level on the buy side, if any.
+func (b *LocalOrderBook) BestBid() (PriceLevel, bool) {
+	bids, _ := b.Depth(1)
+	if len(bids) == 0 {
+		return PriceLevel{}, false
+	}
+	return bids[0], true
+}
+
+// BestAsk returns the lowest price level on the sell side, if any.
+func (b *LocalOrderBook) BestAsk() (PriceLevel, bool) {
+	_, asks := b.Depth(1)
+	if len(asks) == 0 {
+		return PriceLevel{}, false
+	}
+	return asks[0], true
+}
+
+// Depth returns up to n price levels of each side of the book, best prices
+// first. If n is not positive all levels are returned.
+func (b *LocalOrderBook) Depth(n int) (bids []PriceLevel, asks []PriceLevel) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.bids.depth(n), b.asks.depth(n)
+}
+
+// Run keeps the book up to date with the messages received from diffs, which
+// must carry the "diff-orders" channel for the book. Messages for other books
+// are ignored. Run blocks until ctx is done, diffs is closed or a snapshot
+// can not be parsed.
+func (b *LocalOrderBook) Run(ctx context.Context, diffs <-chan WebSocketDiffOrder) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	snapshots := make(chan snapshotResult, 1)
+	b.requestSnapshot(ctx, snapshots, 0)
+
+	// Diffs received while waiting for a snapshot.
+	var pending []WebSocketDiffOrder
+	waiting := true
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case diff, ok := <-diffs:
+			if !ok {
+				return errors.New("diff-orders stream closed")
+			}
+			if diff.Book != b.book {
+				continue
+			}
+			if waiting {
+				pending = append(pending, diff)
+				continue
+			}
+			if err := b.apply(diff); err != nil {
+				b.client.logger.Warn().
+					Str("book", b.book.String()).
+					Err(err).
+					Msg("local order book out of sync, requesting snapshot")
+				b.setSynced(false)
+				pending = append(pending[:0], diff)
+				waiting = true
+				b.requestSnapshot(ctx, snapshots, 0)
+			}
+
+		case res := <-snapshots:
+			if res.err != nil {
+				b.client.logger.Error().
+					Str("book", b.book.String()).
+					Err(res.err).
+					Msg("can not fetch order book snapshot")
+				b.requestSnapshot(ctx, snapshots, defaultResyncDelay)
+				continue
+			}
+			if err := b.load(res.orderBook, pending); err != nil {
+				if errors.Is(err, errInvalidSnapshot) {
+					// Asking again would get the same snapshot.
+					return err
+				}
+				// The snapshot is older than the first diff we have, try
+				// again with a newer one.
+				b.requestSnapshot(ctx, snapshots, defaultResyncDelay)
+				continue
+			}
+			pending = pending[:0]
+			waiting = false
+		}
+	}
+}
+
+func (b *LocalOrderBook) requestSnapshot(ctx context.Context, snapshots chan<- snapshotResult, delay time.Duration) {
+	go func() {
+		if err := sleepContext(ctx, delay); err != nil {
+			return
+		}
+		orderBook, err := b.client.QueryOrderBook(ctx, &OrderBookQuery{
+			Book:         &b.book,
+			Unaggregated: true,
+		})
+		snapshots <- snapshotResult{orderBook: orderBook, err: err}
+	}()
+}
+
+// load replaces the contents of the book with the given snapshot and applies
+// the pending diffs that came after it.
+func (b *LocalOrderBook) load(orderBook *OrderBook, pending []WebSocketDiffOrder) error {
+	sequence, err := strconv.ParseUint(orderBook.Sequence, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: sequence %q: %w", errInvalidSnapshot, orderBook.Sequence, err)
+	}
+
+	bids, asks := newBookSide(true), newBookSide(false)
+	for _, orders := range []struct {
+		src []Order
+		dst *bookSide
+	}{{orderBook.Bids, bids}, {orderBook.Asks, asks}} {
+		for _, o := range orders.src {
+			entry, err := newLocalOrder(o.Price, o.Amount)
+			if err != nil {
+				return fmt.Errorf("%w: order %s: %w", errInvalidSnapshot, o.OID, err)
+			}
+			orders.dst.set(o.OID, entry)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bids, b.asks = bids, asks
+	b.sequence = sequence
+	b.synced = false
+
+	for _, diff := range pending {
+		if err := b.applyLocked(diff); err != nil {
+			return err
+		}
+	}
+
+	b.synced = true
+	b.notify()
+	return nil
+}
+
+// apply applies a single diff-orders message. It returns errSequenceGap if
+// the message does not follow the last one applied.
+func (b *LocalOrderBook) apply(diff WebSocketDiffOrder) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.applyLocked(diff); err != nil {
+		return err
+	}
+	b.notify()
+	return nil
+}
+
+func (b *LocalOrderBook) applyLocked(diff WebSocketDiffOrder) error {
+	if diff.Sequence <= b.sequence {
+		// Already part of the snapshot, or a duplicate.
+		return nil
+	}
+	if diff.Sequence != b.sequence+1 {
+		return fmt.Errorf("%w: expecting %d, got %d", errSequenceGap, b.sequence+1, diff.Sequence)
+	}
+
+	for _, entry := range diff.Payload {
+		side := b.bids
+		if entry.Side == OrderSideSell {
+			side = b.asks
+		}
+
+		if entry.Status != OrderStatusOpen {
+			side.remove(entry.OrderID)
+			continue
+		}
+
+		order, err := newLocalOrder(entry.Price, entry.Amount)
+		if err != nil {
+			b.client.logger.Warn().
+				Str("book", b.book.String()).
+				Str("oid", entry.OrderID).
+				Err(err).
+				Msg("ignoring malformed order")
+			continue
+		}
+		side.set(entry.OrderID, order)
+	}
+
+	b.sequence = diff.Sequence
+	return nil
+}
+
+func newLocalOrder(price, amount Monetary) (localOrder, error) {
+	p, err := price.Decimal()
+	if err != nil {
+		return localOrder{}, err
+	}
+	a, err := amount.Decimal()
+	if err != nil {
+		return localOrder{}, err
+	}
+	return localOrder{price: p, amount: a}, nil
+}
+
+func (b *LocalOrderBook) setSynced(synced bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.synced = synced
+}
+
+func (b *LocalOrderBook) notify() {
+	select {
+	case b.updates <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,213 @@
+package bitso
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func snapshotHandler(t *testing.T, sequences ...string) (http.HandlerFunc, *int32) {
+	var calls int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "false", r.URL.Query().Get("aggregate"))
+
+		n := int(atomic.AddInt32(&calls, 1))
+		sequence := sequences[min(n, len(sequences))-1]
+
+		payload := map[string]interface{}{
+			"bids": []map[string]interface{}{
+				{"book": "btc_mxn", "price": "499000.00", "amount": "1.0", "oid": "bid-1"},
+				{"book": "btc_mxn", "price": "499000.00", "amount": "0.5", "oid": "bid-2"},
+				{"book": "btc_mxn", "price": "498000.00", "amount": "2.0", "oid": "bid-3"},
+			},
+			"asks": []map[string]interface{}{
+				{"book": "btc_mxn", "price": "500000.00", "amount": "0.3", "oid": "ask-1"},
+				{"book": "btc_mxn", "price": "501000.00", "amount": "0.7", "oid": "ask-2"},
+			},
+			"updated_at": "2024-01-15T10:30:00+00:00",
+			"sequence":   sequence,
+		}
+		w.Write(successResponse(payload))
+	}, &calls
+}
+
+func diffOrder(t *testing.T, sequence uint64, entries ...string) WebSocketDiffOrder {
+	data := fmt.Sprintf(`{"type": "diff-orders", "book": "btc_mxn", "sequence": %d, "payload": [`, sequence)
+	for i, entry := range entries {
+		if i > 0 {
+			data += ","
+		}
+		data += entry
+	}
+	data += "]}"
+
+	var diff WebSocketDiffOrder
+	require.NoError(t, json.Unmarshal([]byte(data), &diff))
+	return diff
+}
+
+func runLocalOrderBook(t *testing.T, client *Client) (*LocalOrderBook, chan WebSocketDiffOrder, context.CancelFunc) {
+	book := NewLocalOrderBook(client, *NewBook(BTC, MXN))
+	diffs := make(chan WebSocketDiffOrder, 16)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_ = book.Run(ctx, diffs)
+	}()
+	return book, diffs, cancel
+}
+
+func waitForSequence(t *testing.T, book *LocalOrderBook, sequence uint64) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		return book.Synced() && book.Sequence() == sequence
+	}, time.Second, time.Millisecond)
+}
+
+func TestLocalOrderBook_Snapshot(t *testing.T) {
+	handler, _ := snapshotHandler(t, "100")
+	server, client := mockServer(t, handler)
+	defer server.Close()
+
+	book, _, cancel := runLocalOrderBook(t, client)
+	defer cancel()
+
+	waitForSequence(t, book, 100)
+
+	bid, ok := book.BestBid()
+	require.True(t, ok)
+	assert.Equal(t, PriceLevel{Price: "499000", Amount: "1.5", Orders: 2}, bid)
+
+	ask, ok := book.BestAsk()
+	require.True(t, ok)
+	assert.Equal(t, PriceLevel{Price: "500000", Amount: "0.3", Orders: 1}, ask)
+
+	bids, asks := book.Depth(0)
+	assert.Len(t, bids, 2)
+	assert.Len(t, asks, 2)
+	assert.Equal(t, Monetary("498000"), bids[1].Price)
+	assert.Equal(t, Monetary("501000"), asks[1].Price)
+}
+
+func TestLocalOrderBook_ApplyDiffs(t *testing.T) {
+	handler, _ := snapshotHandler(t, "100")
+	server, client := mockServer(t, handler)
+	defer server.Close()
+
+	book, diffs, cancel := runLocalOrderBook(t, client)
+	defer cancel()
+
+	// Old diffs are dropped, these were already part of the snapshot.
+	diffs <- diffOrder(t, 99, `{"o": "ask-1", "t": 1, "s": "cancelled", "r": "500000.00"}`)
+	waitForSequence(t, book, 100)
+
+	diffs <- diffOrder(t, 101, `{"o": "ask-0", "t": 1, "s": "open", "r": "499500.00", "a": "0.1"}`)
+	diffs <- diffOrder(t, 102, `{"o": "bid-1", "t": 0, "s": "completed", "r": "499000.00"}`)
+	diffs <- diffOrder(t, 103, `{"o": "bid-2", "t": 0, "s": "open", "r": "499000.00", "a": "0.25"}`)
+	waitForSequence(t, book, 103)
+
+	bid, ok := book.BestBid()
+	require.True(t, ok)
+	assert.Equal(t, PriceLevel{Price: "499000", Amount: "0.25", Orders: 1}, bid)
+
+	ask, ok := book.BestAsk()
+	require.True(t, ok)
+	assert.Equal(t, PriceLevel{Price: "499500", Amount: "0.1", Orders: 1}, ask)
+
+	// Emptied levels are removed.
+	diffs <- diffOrder(t, 104, `{"o": "ask-0", "t": 1, "s": "cancelled", "r": "499500.00"}`)
+	waitForSequence(t, book, 104)
+
+	_, asks := book.Depth(0)
+	require.Len(t, asks, 2)
+	assert.Equal(t, Monetary("500000"), asks[0].Price)
+
+	select {
+	case <-book.Updates():
+	default:
+		t.Fatal("expecting a change notification")
+	}
+}
+
+func TestLocalOrderBook_Resync(t *testing.T) {
+	handler, calls := snapshotHandler(t, "100", "200")
+	server, client := mockServer(t, handler)
+	defer server.Close()
+
+	book, diffs, cancel := runLocalOrderBook(t, client)
+	defer cancel()
+
+	waitForSequence(t, book, 100)
+
+	// Sequence 101 is missing.
+	diffs <- diffOrder(t, 102, `{"o": "ask-9", "t": 1, "s": "open", "r": "500500.00", "a": "1"}`)
+	diffs <- diffOrder(t, 201, `{"o": "ask-9", "t": 1, "s": "open", "r": "500500.00", "a": "1"}`)
+
+	waitForSequence(t, book, 201)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+
+	_, asks := book.Depth(0)
+	require.Len(t, asks, 3)
+	assert.Equal(t, Monetary("500500"), asks[1].Price)
+}
+
+func TestLocalOrderBook_IgnoresOtherBooks(t *testing.T) {
+	handler, _ := snapshotHandler(t, "100")
+	server, client := mockServer(t, handler)
+	defer server.Close()
+
+	book, diffs, cancel := runLocalOrderBook(t, client)
+	defer cancel()
+
+	waitForSequence(t, book, 100)
+
+	other := diffOrder(t, 101, `{"o": "x", "t": 0, "s": "open", "r": "1.00", "a": "1"}`)
+	other.Book = *NewBook(ETH, MXN)
+	diffs <- other
+	diffs <- diffOrder(t, 101)
+
+	waitForSequence(t, book, 101)
+	bids, _ := book.Depth(0)
+	assert.Len(t, bids, 2)
+}
+
+func TestLocalOrderBook_StreamClosed(t *testing.T) {
+	handler, _ := snapshotHandler(t, "100")
+	server, client := mockServer(t, handler)
+	defer server.Close()
+
+	book := NewLocalOrderBook(client, *NewBook(BTC, MXN))
+	diffs := make(chan WebSocketDiffOrder)
+	close(diffs)
+
+	err := book.Run(context.Background(), diffs)
+	assert.Error(t, err)
+}
+
+func TestLocalOrderBook_InvalidSnapshot(t *testing.T) {
+	var calls int32
+	server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write(successResponse(map[string]interface{}{
+			"bids": []map[string]interface{}{
+				{"book": "btc_mxn", "price": "not a price", "amount": "1.0", "oid": "bid-1"},
+			},
+			"asks":     []map[string]interface{}{},
+			"sequence": "100",
+		}))
+	})
+	defer server.Close()
+
+	book := NewLocalOrderBook(client, *NewBook(BTC, MXN))
+	err := book.Run(context.Background(), make(chan WebSocketDiffOrder))
+	assert.ErrorIs(t, err, errInvalidSnapshot)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.False(t, book.Synced())
+}
@@ -409,6 +409,52 @@ func TestUserOrderJSON(t *testing.T) {
 		assert.Equal(t, "limit", order.Type)
 	})
 
+	t.Run("post-only stop order", func(t *testing.T) {
+		jsonData := `{
+			"book": "btc_mxn",
+			"original_amount": "0.1",
+			"unfilled_amount": "0.1",
+			"original_value": "50000.00",
+			"created_at": "2024-01-15T10:30:00+00:00",
+			"updated_at": "2024-01-15T10:30:00+00:00",
+			"price": "500000.00",
+			"stop": "490000.00",
+			"oid": "order789",
+			"origin_id": "my-order-1",
+			"side": "sell",
+			"status": "queued",
+			"type": "limit",
+			"time_in_force": "postonly"
+		}`
+
+		var order UserOrder
+		err := json.Unmarshal([]byte(jsonData), &order)
+
+		require.NoError(t, err)
+		assert.Equal(t, TimeInForcePostOnly, order.TimeInForce)
+		assert.Equal(t, "490000.00", string(order.Stop))
+		assert.Equal(t, "my-order-1", order.OriginID)
+	})
+
+	t.Run("market order without time in force", func(t *testing.T) {
+		jsonData := `{
+			"book": "btc_mxn",
+			"oid": "order790",
+			"side": "buy",
+			"status": "completed",
+			"type": "market",
+			"time_in_force": null,
+			"created_at": "2024-01-15T10:30:00+00:00",
+			"updated_at": "2024-01-15T10:30:00+00:00"
+		}`
+
+		var order UserOrder
+		err := json.Unmarshal([]byte(jsonData), &order)
+
+		require.NoError(t, err)
+		assert.Equal(t, TimeInForceNone, order.TimeInForce)
+	})
+
 	t.Run("partially filled order", func(t *testing.T) {
 		jsonData := `{
 			"book": "eth_mxn",
@@ -486,6 +532,46 @@ func TestOrderPlacementJSON(t *testing.T) {
 		assert.Equal(t, OrderSideBuy, order.Side)
 		assert.Equal(t, OrderTypeLimit, order.Type)
 	})
+
+	t.Run("optional fields are omitted", func(t *testing.T) {
+		order := OrderPlacement{
+			Book:  *NewBook(BTC, MXN),
+			Side:  OrderSideBuy,
+			Type:  OrderTypeLimit,
+			Major: ToMonetary(0.1),
+			Price: ToMonetary(500000),
+		}
+
+		data, err := json.Marshal(order)
+
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "time_in_force")
+		assert.NotContains(t, string(data), "stop")
+		assert.NotContains(t, string(data), "origin_id")
+	})
+
+	t.Run("roundtrip with time in force, stop and origin id", func(t *testing.T) {
+		order := OrderPlacement{
+			Book:        *NewBook(ETH, MXN),
+			Side:        OrderSideSell,
+			Type:        OrderTypeLimit,
+			Major:       "1.5",
+			Price:       "35000.00",
+			Stop:        "34000.00",
+			TimeInForce: TimeInForcePostOnly,
+			OriginID:    "quote-42",
+		}
+
+		data, err := json.Marshal(order)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"time_in_force":"postonly"`)
+		assert.Contains(t, string(data), `"stop":"34000.00"`)
+		assert.Contains(t, string(data), `"origin_id":"quote-42"`)
+
+		var decoded OrderPlacement
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, order, decoded)
+	})
 }
 
 func TestTransactionJSON(t *testing.T) {
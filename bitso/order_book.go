@@ -37,6 +37,10 @@ type UserOrder struct {
 	Side   OrderSide   `json:"side"`
 	Status OrderStatus `json:"status"`
 	Type   string      `json:"type"`
+
+	TimeInForce TimeInForce `json:"time_in_force"`
+	Stop        Monetary    `json:"stop"`
+	OriginID    string      `json:"origin_id"`
 }
 
 // OrderPlacement represents an order that can be set by the user.
@@ -49,4 +53,13 @@ type OrderPlacement struct {
 	Major Monetary `json:"major,omitempty"`
 	Minor Monetary `json:"minor,omitempty"`
 	Price Monetary `json:"price,omitempty"`
+
+	// Price that triggers the order, only for stop orders.
+	Stop Monetary `json:"stop,omitempty"`
+	// How long a limit order remains active, Bitso defaults to
+	// TimeInForceGoodTillCancelled.
+	TimeInForce TimeInForce `json:"time_in_force,omitempty"`
+
+	// Client-supplied order ID, must be unique among the user's open orders.
+	OriginID string `json:"origin_id,omitempty"`
 }
@@ -0,0 +1,128 @@
+package bitso
+
+import (
+	"context"
+	"iter"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// maxPageSize is the largest page size accepted by Bitso's list endpoints.
+const maxPageSize = 100
+
+// paginate returns an iterator that walks through a marker-paginated listing.
+// Pages are requested with the largest page size unless params sets a smaller
+// "limit".
+//
+// If until is not zero, iteration stops at the first item created beyond
+// until in the direction of the listing: items older than until when sorting
+// in descending order (the default) and newer than until when sorting in
+// ascending order.
+func paginate[T any](
+	ctx context.Context,
+	params url.Values,
+	until time.Time,
+	fetch func(context.Context, url.Values) ([]T, error),
+	marker func(T) string,
+	createdAt func(T) time.Time,
+) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		query := url.Values{}
+		for k, v := range params {
+			query[k] = append([]string(nil), v...)
+		}
+
+		// Bitso never returns more than maxPageSize items, a larger limit
+		// would end the iteration after the first page.
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit <= 0 || limit > maxPageSize {
+			limit = maxPageSize
+		}
+		query.Set("limit", strconv.Itoa(limit))
+		ascending := query.Get("sort") == "asc"
+
+		for {
+			page, err := fetch(ctx, query)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+
+			for _, item := range page {
+				if !until.IsZero() {
+					t := createdAt(item)
+					if (ascending && t.After(until)) || (!ascending && t.Before(until)) {
+						return
+					}
+				}
+				if !yield(item, nil) {
+					return
+				}
+			}
+
+			if len(page) < limit {
+				return
+			}
+			query.Set("marker", marker(page[len(page)-1]))
+		}
+	}
+}
+
+func tidMarker(tid TID) string {
+	return strconv.FormatUint(tid.Uint64(), 10)
+}
+
+// LedgerSeq returns an iterator over the user's registered operations that
+// requests pages as they are needed. Iteration ends after the last page or, if
+// until is not zero, at the first operation created before until (after
+// until, when params asks for ascending order).
+func (c *Client) LedgerSeq(ctx context.Context, params url.Values, until time.Time) iter.Seq2[Transaction, error] {
+	return paginate(ctx, params, until, c.LedgerContext,
+		func(tx Transaction) string { return tx.EID },
+		func(tx Transaction) time.Time { return tx.CreatedAt.Time() },
+	)
+}
+
+// TradesSeq is like LedgerSeq but iterates over the recent trades of the book
+// given in params.
+func (c *Client) TradesSeq(ctx context.Context, params url.Values, until time.Time) iter.Seq2[Trade, error] {
+	return paginate(ctx, params, until, c.TradesContext,
+		func(trade Trade) string { return tidMarker(trade.TID) },
+		func(trade Trade) time.Time { return trade.CreatedAt.Time() },
+	)
+}
+
+// MyTradesSeq is like LedgerSeq but iterates over the user's trades.
+func (c *Client) MyTradesSeq(ctx context.Context, params url.Values, until time.Time) iter.Seq2[UserTrade, error] {
+	return paginate(ctx, params, until, c.MyTradesContext,
+		func(trade UserTrade) string { return tidMarker(trade.TID) },
+		func(trade UserTrade) time.Time { return trade.CreatedAt.Time() },
+	)
+}
+
+// FundingsSeq is like LedgerSeq but iterates over the user's fundings.
+func (c *Client) FundingsSeq(ctx context.Context, params url.Values, until time.Time) iter.Seq2[Funding, error] {
+	return paginate(ctx, params, until, c.FundingsContext,
+		func(funding Funding) string { return funding.FID },
+		func(funding Funding) time.Time { return funding.CreatedAt.Time() },
+	)
+}
+
+// WithdrawalsSeq is like LedgerSeq but iterates over the user's withdrawals.
+func (c *Client) WithdrawalsSeq(ctx context.Context, params url.Values, until time.Time) iter.Seq2[Withdrawal, error] {
+	return paginate(ctx, params, until, c.WithdrawalsContext,
+		func(withdrawal Withdrawal) string { return withdrawal.WID },
+		func(withdrawal Withdrawal) time.Time { return withdrawal.CreatedAt.Time() },
+	)
+}
+
+// MyOpenOrdersSeq is like LedgerSeq but iterates over the user's open
+// orders.
+func (c *Client) MyOpenOrdersSeq(ctx context.Context, params url.Values, until time.Time) iter.Seq2[UserOrder, error] {
+	return paginate(ctx, params, until, c.MyOpenOrdersContext,
+		func(order UserOrder) string { return order.OID },
+		func(order UserOrder) time.Time { return order.CreatedAt.Time() },
+	)
+}
@@ -0,0 +1,198 @@
+package bitso
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedTrades serves n trades with descending TIDs, starting at n, and
+// honors the "marker" and "limit" parameters. Like Bitso, it serves
+// maxPageSize trades at most.
+func pagedTrades(t *testing.T, n int, requests *int32) http.HandlerFunc {
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		require.NoError(t, err)
+		limit = min(limit, maxPageSize)
+
+		start := n
+		if marker := r.URL.Query().Get("marker"); marker != "" {
+			start, err = strconv.Atoi(marker)
+			require.NoError(t, err)
+			start--
+		}
+
+		payload := []map[string]interface{}{}
+		for tid := start; tid > 0 && len(payload) < limit; tid-- {
+			payload = append(payload, map[string]interface{}{
+				"book":       "btc_mxn",
+				"created_at": base.Add(time.Duration(tid) * time.Minute).Format("2006-01-02T15:04:05-07:00"),
+				"amount":     "0.1",
+				"maker_side": "buy",
+				"price":      "500000.00",
+				"tid":        tid,
+			})
+		}
+		w.Write(successResponse(payload))
+	}
+}
+
+func TestTradesSeq(t *testing.T) {
+	var requests int32
+	server, client := mockServer(t, pagedTrades(t, 25, &requests))
+	defer server.Close()
+
+	params := url.Values{"book": {"btc_mxn"}, "limit": {"10"}}
+
+	var tids []uint64
+	for trade, err := range client.TradesSeq(context.Background(), params, time.Time{}) {
+		require.NoError(t, err)
+		tids = append(tids, trade.TID.Uint64())
+	}
+
+	require.Len(t, tids, 25)
+	assert.Equal(t, uint64(25), tids[0])
+	assert.Equal(t, uint64(1), tids[24])
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+
+	// The caller's parameters are left untouched.
+	assert.Empty(t, params.Get("marker"))
+}
+
+func TestTradesSeq_LargeLimit(t *testing.T) {
+	var requests int32
+	server, client := mockServer(t, pagedTrades(t, 150, &requests))
+	defer server.Close()
+
+	params := url.Values{"book": {"btc_mxn"}, "limit": {"500"}}
+
+	count := 0
+	for _, err := range client.TradesSeq(context.Background(), params, time.Time{}) {
+		require.NoError(t, err)
+		count++
+	}
+	assert.Equal(t, 150, count)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestTradesSeq_DefaultLimit(t *testing.T) {
+	var requests int32
+	server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		assert.Equal(t, strconv.Itoa(maxPageSize), r.URL.Query().Get("limit"))
+		w.Write(successResponse([]interface{}{}))
+	})
+	defer server.Close()
+
+	for _, err := range client.TradesSeq(context.Background(), nil, time.Time{}) {
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestTradesSeq_Until(t *testing.T) {
+	var requests int32
+	server, client := mockServer(t, pagedTrades(t, 50, &requests))
+	defer server.Close()
+
+	// Trade N was created N minutes after the base time.
+	until := time.Date(2024, 1, 15, 10, 35, 0, 0, time.UTC)
+	params := url.Values{"book": {"btc_mxn"}, "limit": {"10"}}
+
+	var tids []uint64
+	for trade, err := range client.TradesSeq(context.Background(), params, until) {
+		require.NoError(t, err)
+		tids = append(tids, trade.TID.Uint64())
+	}
+
+	require.Len(t, tids, 16)
+	assert.Equal(t, uint64(35), tids[len(tids)-1])
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestTradesSeq_Break(t *testing.T) {
+	var requests int32
+	server, client := mockServer(t, pagedTrades(t, 100, &requests))
+	defer server.Close()
+
+	params := url.Values{"book": {"btc_mxn"}, "limit": {"10"}}
+
+	count := 0
+	for _, err := range client.TradesSeq(context.Background(), params, time.Time{}) {
+		require.NoError(t, err)
+		count++
+		if count == 15 {
+			break
+		}
+	}
+
+	assert.Equal(t, 15, count)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestLedgerSeq_Error(t *testing.T) {
+	server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("marker") == "" {
+			payload := []map[string]interface{}{}
+			for i := 0; i < 2; i++ {
+				payload = append(payload, map[string]interface{}{
+					"eid":        fmt.Sprintf("eid-%d", i),
+					"operation":  "trade",
+					"created_at": "2024-01-15T10:30:00+00:00",
+				})
+			}
+			w.Write(successResponse(payload))
+			return
+		}
+		assert.Equal(t, "eid-1", r.URL.Query().Get("marker"))
+		w.Write(errorResponse(101, "Unknown error"))
+	})
+	defer server.Close()
+
+	client.SetAuth("test-key", "test-secret")
+
+	var eids []string
+	var lastErr error
+	for tx, err := range client.LedgerSeq(context.Background(), url.Values{"limit": {"2"}}, time.Time{}) {
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		eids = append(eids, tx.EID)
+	}
+
+	assert.Equal(t, []string{"eid-0", "eid-1"}, eids)
+	assert.ErrorIs(t, lastErr, ErrUnknown)
+}
+
+func TestMyOpenOrdersSeq_Context(t *testing.T) {
+	server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(successResponse([]interface{}{}))
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client.SetAuth("test-key", "test-secret")
+
+	var errs []error
+	for _, err := range client.MyOpenOrdersSeq(ctx, nil, time.Time{}) {
+		errs = append(errs, err)
+	}
+
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], context.Canceled)
+}
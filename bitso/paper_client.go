@@ -0,0 +1,658 @@
+package bitso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/xiam/bitso-go/bitso/internal/matching"
+)
+
+var errPaperStreamClosed = errors.New("market data stream closed")
+
+// A PaperClient simulates trading on Bitso without risking funds. It keeps
+// virtual balances and fills orders against the market data given to Feed or
+// Run:
+//
+//   - Orders that cross the book take liquidity from the orders of other
+//     traders, as known from the last "orders" snapshot of their book and the
+//     "diff-orders" messages after it, paying the taker fee. Market orders
+//     placed before any of those messages wait for them. Orders of other
+//     traders are tracked by ID, the ones without an ID are ignored.
+//   - Limit orders resting on the book are filled at their own price, paying
+//     the maker fee, by public trades at or through that price, up to the
+//     traded amount. Only trades that hit the side of the book of an order
+//     fill it: a trade whose maker was a buyer fills buy orders.
+//
+// Fees are taken from the flat rate of each ExchangeOrderBook. As on Bitso,
+// buyers pay fees in the major currency and sellers in the minor currency.
+type PaperClient struct {
+	// books holds the orders of other traders, minus what the user took
+	// from them.
+	books    map[Book]*matching.Book
+	bookList []Book
+	// taken holds how much the user took from each order of other traders,
+	// by book and order ID. Snapshots and diffs still list what was taken,
+	// it's subtracted when they add those orders again.
+	taken map[*matching.Book]map[string]decimal.Decimal
+
+	account *matching.Account
+	lastOID uint64
+	lastTID uint64
+
+	now func() time.Time
+
+	mu sync.Mutex
+}
+
+var _ Trading = (*PaperClient)(nil)
+
+// NewPaperClient creates a paper trading client for the given books, which
+// can be fetched with AvailableBooks. All balances start at zero.
+func NewPaperClient(books []ExchangeOrderBook) *PaperClient {
+	p := &PaperClient{
+		books: map[Book]*matching.Book{},
+		taken: map[*matching.Book]map[string]decimal.Decimal{},
+		now:   time.Now,
+	}
+	p.account = &matching.Account{
+		Balances: map[string]decimal.Decimal{},
+		NewOID: func() string {
+			p.lastOID++
+			return "paper-" + strconv.FormatUint(p.lastOID, 10)
+		},
+		NewTID: func() uint64 {
+			p.lastTID++
+			return p.lastTID
+		},
+		OnFill: p.onFill,
+	}
+
+	for _, config := range books {
+		if _, ok := p.books[config.Book]; !ok {
+			p.bookList = append(p.bookList, config.Book)
+		}
+		b := newMatchingBook(config)
+		p.books[config.Book] = b
+		p.taken[b] = map[string]decimal.Decimal{}
+	}
+	return p
+}
+
+// newMatchingBook creates the book of the matching engine for config.
+func newMatchingBook(config ExchangeOrderBook) *matching.Book {
+	return matching.NewBook(
+		config.Book.Major().String(),
+		config.Book.Minor().String(),
+		string(config.Fees.FlatRate.Maker),
+		string(config.Fees.FlatRate.Taker),
+		matching.Limits{
+			MinAmount: string(config.MinimumAmount),
+			MaxAmount: string(config.MaximumAmount),
+			MinPrice:  string(config.MinimumPrice),
+			MaxPrice:  string(config.MaximumPrice),
+			MinValue:  string(config.MinimumValue),
+			MaxValue:  string(config.MaximumValue),
+		},
+	)
+}
+
+// SetClock sets the function used to timestamp orders and trades, the
+// default is time.Now. Replays may want to use the time of the data instead.
+func (p *PaperClient) SetClock(now func() time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.now = now
+}
+
+// SetBalance sets the total virtual balance of a currency.
+func (p *PaperClient) SetBalance(currency Currency, total Monetary) error {
+	amount, err := total.Decimal()
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.account.Balances[currency.String()] = amount
+	return nil
+}
+
+// Feed updates the client with a message from the "trades", "diff-orders" or
+// "orders" channels, as returned by DecodeWebSocketMessage, and fills the
+// orders it affects. Other messages and books are ignored.
+func (p *PaperClient) Feed(msg interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch m := msg.(type) {
+	case WebSocketTrade:
+		p.applyTrades(m)
+	case *WebSocketTrade:
+		p.applyTrades(*m)
+	case WebSocketOrder:
+		p.applySnapshot(m)
+	case *WebSocketOrder:
+		p.applySnapshot(*m)
+	case WebSocketDiffOrder:
+		p.applyDiff(m)
+	case *WebSocketDiffOrder:
+		p.applyDiff(*m)
+	}
+}
+
+// Run subscribes to the trades, diff-orders and orders channels of every book
+// and feeds the client with them. It blocks until ctx is done or a stream is
+// closed.
+func (p *PaperClient) Run(ctx context.Context, ws *WebSocketConn) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var streams []interface{}
+	defer func() {
+		for _, stream := range streams {
+			_ = ws.Unsubscribe(stream)
+		}
+	}()
+
+	closed := make(chan struct{}, 3*len(p.bookList))
+	for _, book := range p.bookList {
+		trades, err := ws.SubscribeTrades(&book)
+		if err != nil {
+			return err
+		}
+		streams = append(streams, trades)
+		go feedFrom(ctx, trades, p.Feed, closed)
+
+		diffs, err := ws.SubscribeDiffOrders(&book)
+		if err != nil {
+			return err
+		}
+		streams = append(streams, diffs)
+		go feedFrom(ctx, diffs, p.Feed, closed)
+
+		orders, err := ws.SubscribeOrders(&book)
+		if err != nil {
+			return err
+		}
+		streams = append(streams, orders)
+		go feedFrom(ctx, orders, p.Feed, closed)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-closed:
+		return errPaperStreamClosed
+	}
+}
+
+func feedFrom[T any](ctx context.Context, ch <-chan T, feed func(interface{}), closed chan<- struct{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				closed <- struct{}{}
+				return
+			}
+			feed(msg)
+		}
+	}
+}
+
+func (p *PaperClient) applySnapshot(msg WebSocketOrder) {
+	b, ok := p.books[msg.Book]
+	if !ok {
+		return
+	}
+
+	// Orders missing from the snapshot are gone, and so is what was taken
+	// from them.
+	listed := map[string]bool{}
+	for _, orders := range [][]StreamOrder{msg.Payload.Bids, msg.Payload.Asks} {
+		for _, o := range orders {
+			listed[o.OrderID] = true
+		}
+	}
+	maps.DeleteFunc(p.taken[b], func(id string, _ decimal.Decimal) bool {
+		return !listed[id]
+	})
+
+	b.Bids, b.Asks = nil, nil
+	for _, o := range msg.Payload.Bids {
+		p.restEntry(b, o.OrderID, OrderSideBuy, o.Price, o.Amount)
+	}
+	for _, o := range msg.Payload.Asks {
+		p.restEntry(b, o.OrderID, OrderSideSell, o.Price, o.Amount)
+	}
+	p.account.Refresh(b, p.now())
+}
+
+func (p *PaperClient) applyDiff(msg WebSocketDiffOrder) {
+	b, ok := p.books[msg.Book]
+	if !ok {
+		return
+	}
+
+	for _, diff := range msg.Payload {
+		if entry := b.Find(diff.OrderID); entry != nil {
+			b.Remove(entry)
+		}
+		if diff.Status == OrderStatusOpen {
+			p.restEntry(b, diff.OrderID, diff.Side, diff.Price, diff.Amount)
+		} else {
+			delete(p.taken[b], diff.OrderID)
+		}
+	}
+	p.account.Refresh(b, p.now())
+}
+
+// restEntry adds an order of another trader to a book, minus what the user
+// already took from it. Invalid orders, and orders without an ID that later
+// diffs couldn't update, are ignored.
+func (p *PaperClient) restEntry(b *matching.Book, id string, side OrderSide, rawPrice, rawAmount Monetary) {
+	if id == "" {
+		return
+	}
+	price, err := rawPrice.Decimal()
+	if err != nil {
+		return
+	}
+	amount, err := rawAmount.Decimal()
+	if err != nil {
+		return
+	}
+	amount = amount.Sub(p.taken[b][id])
+	if !amount.IsPositive() {
+		return
+	}
+	if side != OrderSideBuy && side != OrderSideSell {
+		return
+	}
+	b.Rest(&matching.Order{
+		ID:       id,
+		Book:     b,
+		Side:     matching.Side(side),
+		Type:     matching.Limit,
+		Price:    price,
+		Amount:   amount,
+		Unfilled: amount,
+		Status:   matching.Open,
+	})
+}
+
+// onFill records what the user took from an order of another trader.
+func (p *PaperClient) onFill(fill matching.Fill) {
+	taken := p.taken[fill.Book]
+	taken[fill.MakerID] = taken[fill.MakerID].Add(fill.Amount)
+}
+
+func (p *PaperClient) applyTrades(msg WebSocketTrade) {
+	b, ok := p.books[msg.Book]
+	if !ok {
+		return
+	}
+
+	now := p.now()
+	for _, trade := range msg.Payload {
+		if trade.MakerSide != OrderSideBuy && trade.MakerSide != OrderSideSell {
+			continue
+		}
+		price, err := trade.Price.Decimal()
+		if err != nil {
+			continue
+		}
+		amount, err := trade.Amount.Decimal()
+		if err != nil {
+			continue
+		}
+		p.account.FillResting(b, matching.Side(trade.MakerSide), price, amount, now)
+	}
+}
+
+// paperErrors maps the reasons of the matching engine to the errors of the
+// package.
+var paperErrors = map[matching.Reason]error{
+	matching.ReasonInvalidPayload:     ErrInvalidPayload,
+	matching.ReasonInvalidSide:        ErrInvalidOrderSide,
+	matching.ReasonInvalidType:        ErrInvalidOrderType,
+	matching.ReasonInvalidAmount:      ErrInvalidAmount,
+	matching.ReasonInvalidPrice:       ErrInvalidPrice,
+	matching.ReasonDuplicatedOriginID: ErrDuplicatedOriginID,
+	matching.ReasonInsufficientFunds:  ErrInsufficientFunds,
+}
+
+func paperError(err error) error {
+	var rejection *matching.Rejection
+	if errors.As(err, &rejection) {
+		return fmt.Errorf("%w: %s", paperErrors[rejection.Reason], rejection.Detail)
+	}
+	return err
+}
+
+func paperUserOrder(o *matching.Order) UserOrder {
+	res := UserOrder{
+		Book:           *NewBook(ToCurrency(o.Book.Major), ToCurrency(o.Book.Minor)),
+		OriginalAmount: Monetary(o.Amount.String()),
+		UnfilledAmount: Monetary(o.Unfilled.String()),
+		CreatedAt:      Time(o.CreatedAt),
+		UpdatedAt:      Time(o.UpdatedAt),
+		OID:            o.ID,
+		Side:           OrderSide(o.Side),
+		Status:         OrderStatus(o.Status),
+		Type:           OrderType(o.Type).String(),
+		TimeInForce:    TimeInForce(o.TimeInForce),
+		OriginID:       o.OriginID,
+	}
+	if o.Type == matching.Limit {
+		res.Price = Monetary(o.Price.String())
+		res.OriginalValue = Monetary(o.Price.Mul(o.Amount).String())
+	}
+	if o.ByMinor {
+		res.OriginalValue = Monetary(o.Value.String())
+	}
+	return res
+}
+
+func paperUserOrders(orders []*matching.Order) []UserOrder {
+	res := make([]UserOrder, 0, len(orders))
+	for _, o := range orders {
+		res = append(res, paperUserOrder(o))
+	}
+	return res
+}
+
+func paperUserTrade(trade matching.Trade) UserTrade {
+	o := trade.Order
+	return UserTrade{
+		Book:         *NewBook(ToCurrency(o.Book.Major), ToCurrency(o.Book.Minor)),
+		Major:        Monetary(trade.Major.String()),
+		CreatedAt:    Time(trade.CreatedAt),
+		Minor:        Monetary(trade.Minor.String()),
+		FeesAmount:   Monetary(trade.Fee.String()),
+		FeesCurrency: ToCurrency(trade.FeeCurrency),
+		Price:        Monetary(trade.Price.String()),
+		TID:          TID(trade.TID),
+		OID:          o.ID,
+		Side:         OrderSide(o.Side),
+	}
+}
+
+// PlaceOrder places a simulated order.
+func (p *PaperClient) PlaceOrder(order *OrderPlacement) (string, error) {
+	return p.PlaceOrderContext(context.Background(), order)
+}
+
+// PlaceOrderContext is like PlaceOrder but takes a context to match
+// Client.PlaceOrderContext, the context is not used.
+func (p *PaperClient) PlaceOrderContext(ctx context.Context, placement *OrderPlacement) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b, ok := p.books[placement.Book]
+	if !ok {
+		return "", fmt.Errorf("%w: %v", ErrUnknownBook, placement.Book)
+	}
+
+	o, err := p.account.Place(b, matching.Placement{
+		Side:        matching.Side(placement.Side),
+		Type:        matching.Type(placement.Type),
+		TimeInForce: matching.TimeInForce(placement.TimeInForce),
+		OriginID:    placement.OriginID,
+		Major:       string(placement.Major),
+		Minor:       string(placement.Minor),
+		Price:       string(placement.Price),
+		Stop:        string(placement.Stop),
+	}, p.now())
+	if err != nil {
+		return "", paperError(err)
+	}
+	return o.ID, nil
+}
+
+// Balances returns the virtual balances.
+func (p *PaperClient) Balances(params url.Values) ([]Balance, error) {
+	return p.BalancesContext(context.Background(), params)
+}
+
+// BalancesContext is like Balances but takes a context to match
+// Client.BalancesContext, the context is not used.
+func (p *PaperClient) BalancesContext(ctx context.Context, params url.Values) ([]Balance, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	currencies := make([]string, 0, len(p.account.Balances))
+	for currency := range p.account.Balances {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	balances := make([]Balance, 0, len(currencies))
+	for _, currency := range currencies {
+		total, locked := p.account.Balances[currency], p.account.Locked(currency)
+		balances = append(balances, Balance{
+			Currency:          ToCurrency(currency),
+			Total:             Monetary(total.String()),
+			Locked:            Monetary(locked.String()),
+			Available:         Monetary(total.Sub(locked).String()),
+			PendingDeposit:    "0",
+			PendingWithdrawal: "0",
+		})
+	}
+	return balances, nil
+}
+
+// MyTrades returns the simulated trades. Like on Bitso, params may set
+// "book", "marker", "sort" and "limit".
+func (p *PaperClient) MyTrades(params url.Values) ([]UserTrade, error) {
+	return p.MyTradesContext(context.Background(), params)
+}
+
+// MyTradesContext is like MyTrades but takes a context to match
+// Client.MyTradesContext, the context is not used.
+func (p *PaperClient) MyTradesContext(ctx context.Context, params url.Values) ([]UserTrade, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	book := params.Get("book")
+	var trades []UserTrade
+	for _, trade := range p.account.Trades {
+		if t := paperUserTrade(trade); book == "" || t.Book.String() == book {
+			trades = append(trades, t)
+		}
+	}
+	trades, err := matching.Page(trades, func(trade UserTrade) string {
+		return tidMarker(trade.TID)
+	}, params)
+	return trades, paperError(err)
+}
+
+// OrderTrades returns the simulated trades of an order.
+func (p *PaperClient) OrderTrades(oid string, params url.Values) ([]UserOrderTrade, error) {
+	return p.OrderTradesContext(context.Background(), oid, params)
+}
+
+// OrderTradesContext is like OrderTrades but takes a context to match
+// Client.OrderTradesContext, the context is not used.
+func (p *PaperClient) OrderTradesContext(ctx context.Context, oid string, params url.Values) ([]UserOrderTrade, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.account.Lookup(oid) == nil {
+		return nil, fmt.Errorf("%w: %q", ErrOrderNotFound, oid)
+	}
+
+	trades := []UserOrderTrade{}
+	for _, trade := range p.account.Trades {
+		if trade.Order.ID == oid {
+			trades = append(trades, UserOrderTrade(paperUserTrade(trade)))
+		}
+	}
+	return trades, nil
+}
+
+// MyOpenOrders returns the simulated orders that are still open. Like on
+// Bitso, params may set "book", "marker", "sort" and "limit".
+func (p *PaperClient) MyOpenOrders(params url.Values) ([]UserOrder, error) {
+	return p.MyOpenOrdersContext(context.Background(), params)
+}
+
+// MyOpenOrdersContext is like MyOpenOrders but takes a context to match
+// Client.MyOpenOrdersContext, the context is not used.
+func (p *PaperClient) MyOpenOrdersContext(ctx context.Context, params url.Values) ([]UserOrder, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	book := params.Get("book")
+	var open []UserOrder
+	for _, o := range p.account.Orders {
+		if !o.IsOpen() {
+			continue
+		}
+		if order := paperUserOrder(o); book == "" || order.Book.String() == book {
+			open = append(open, order)
+		}
+	}
+	open, err := matching.Page(open, func(o UserOrder) string {
+		return o.OID
+	}, params)
+	return open, paperError(err)
+}
+
+// LookupOrder returns the details of a simulated order.
+func (p *PaperClient) LookupOrder(oid string) (*UserOrder, error) {
+	return p.LookupOrderContext(context.Background(), oid)
+}
+
+// LookupOrderContext is like LookupOrder but takes a context to match
+// Client.LookupOrderContext, the context is not used.
+func (p *PaperClient) LookupOrderContext(ctx context.Context, oid string) (*UserOrder, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	o := p.account.Lookup(oid)
+	if o == nil {
+		return nil, fmt.Errorf("%w: %q", ErrOrderNotFound, oid)
+	}
+	order := paperUserOrder(o)
+	return &order, nil
+}
+
+// LookupOrders returns the details of the given simulated orders, unknown
+// IDs are ignored.
+func (p *PaperClient) LookupOrders(oids []string) ([]UserOrder, error) {
+	return p.LookupOrdersContext(context.Background(), oids)
+}
+
+// LookupOrdersContext is like LookupOrders but takes a context to match
+// Client.LookupOrdersContext, the context is not used.
+func (p *PaperClient) LookupOrdersContext(ctx context.Context, oids []string) ([]UserOrder, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return paperUserOrders(p.account.Select(oids, false)), nil
+}
+
+// LookupOrdersByOriginID is like LookupOrders but takes client-supplied IDs.
+func (p *PaperClient) LookupOrdersByOriginID(originIDs []string) ([]UserOrder, error) {
+	return p.LookupOrdersByOriginIDContext(context.Background(), originIDs)
+}
+
+// LookupOrdersByOriginIDContext is like LookupOrdersByOriginID but takes a
+// context to match Client.LookupOrdersByOriginIDContext, the context is not
+// used.
+func (p *PaperClient) LookupOrdersByOriginIDContext(ctx context.Context, originIDs []string) ([]UserOrder, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return paperUserOrders(p.account.Select(originIDs, true)), nil
+}
+
+// CancelOrder cancels a simulated order.
+func (p *PaperClient) CancelOrder(oid string) ([]string, error) {
+	return p.CancelOrderContext(context.Background(), oid)
+}
+
+// CancelOrderContext is like CancelOrder but takes a context to match
+// Client.CancelOrderContext, the context is not used.
+func (p *PaperClient) CancelOrderContext(ctx context.Context, oid string) ([]string, error) {
+	return p.CancelOrdersContext(ctx, []string{oid})
+}
+
+// CancelOrders cancels the given simulated orders and returns the IDs of
+// the ones that were open.
+func (p *PaperClient) CancelOrders(oids []string) ([]string, error) {
+	return p.CancelOrdersContext(context.Background(), oids)
+}
+
+// CancelOrdersContext is like CancelOrders but takes a context to match
+// Client.CancelOrdersContext, the context is not used.
+func (p *PaperClient) CancelOrdersContext(ctx context.Context, oids []string) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.account.Cancel(p.account.Select(oids, false), false, p.now()), nil
+}
+
+// CancelOrdersByOriginID is like CancelOrders but takes client-supplied IDs.
+func (p *PaperClient) CancelOrdersByOriginID(originIDs []string) ([]string, error) {
+	return p.CancelOrdersByOriginIDContext(context.Background(), originIDs)
+}
+
+// CancelOrdersByOriginIDContext is like CancelOrdersByOriginID but takes a
+// context to match Client.CancelOrdersByOriginIDContext, the context is not
+// used.
+func (p *PaperClient) CancelOrdersByOriginIDContext(ctx context.Context, originIDs []string) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.account.Cancel(p.account.Select(originIDs, true), true, p.now()), nil
+}
+
+// CancelAllOrders cancels every open simulated order.
+func (p *PaperClient) CancelAllOrders() ([]string, error) {
+	return p.CancelAllOrdersContext(context.Background())
+}
+
+// CancelAllOrdersContext is like CancelAllOrders but takes a context to
+// match Client.CancelAllOrdersContext, the context is not used.
+func (p *PaperClient) CancelAllOrdersContext(ctx context.Context) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.account.Cancel(p.account.Orders, false, p.now()), nil
+}
+
+// CancelBookOrders cancels every open simulated order on a book.
+func (p *PaperClient) CancelBookOrders(book *Book) ([]string, error) {
+	return p.CancelBookOrdersContext(context.Background(), book)
+}
+
+// CancelBookOrdersContext is like CancelBookOrders but takes a context to
+// match Client.CancelBookOrdersContext, the context is not used.
+func (p *PaperClient) CancelBookOrdersContext(ctx context.Context, book *Book) ([]string, error) {
+	if book == nil {
+		return nil, errMissingBook
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b := p.books[*book]
+	orders := slices.DeleteFunc(slices.Clone(p.account.Orders), func(o *matching.Order) bool {
+		return o.Book != b
+	})
+	return p.account.Cancel(orders, false, p.now()), nil
+}
@@ -0,0 +1,415 @@
+package bitso
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiam/bitso-go/bitso/internal/matching"
+)
+
+func newTestPaperClient(t *testing.T) *PaperClient {
+	t.Helper()
+
+	p := NewPaperClient([]ExchangeOrderBook{{
+		Book:          *NewBook(BTC, MXN),
+		MinimumAmount: "0.0001",
+		Fees: BookFees{
+			FlatRate: BookFlatRate{Maker: "0.5", Taker: "0.65"},
+		},
+	}})
+	require.NoError(t, p.SetBalance(BTC, "1"))
+	require.NoError(t, p.SetBalance(MXN, "1000000"))
+	return p
+}
+
+func paperSnapshot(bids, asks [][2]Monetary) WebSocketOrder {
+	msg := WebSocketOrder{Book: *NewBook(BTC, MXN)}
+	for i, bid := range bids {
+		msg.Payload.Bids = append(msg.Payload.Bids, StreamOrder{OrderID: fmt.Sprintf("b%d", i+1), Side: OrderSideBuy, Price: bid[0], Amount: bid[1]})
+	}
+	for i, ask := range asks {
+		msg.Payload.Asks = append(msg.Payload.Asks, StreamOrder{OrderID: fmt.Sprintf("a%d", i+1), Side: OrderSideSell, Price: ask[0], Amount: ask[1]})
+	}
+	return msg
+}
+
+func paperTrade(makerSide OrderSide, price, amount Monetary) WebSocketTrade {
+	return WebSocketTrade{
+		Book:    *NewBook(BTC, MXN),
+		Payload: []StreamTrade{{MakerSide: makerSide, Price: price, Amount: amount}},
+	}
+}
+
+func paperBalance(t *testing.T, p *PaperClient, currency Currency) Balance {
+	t.Helper()
+
+	balances, err := p.Balances(nil)
+	require.NoError(t, err)
+	for _, balance := range balances {
+		if balance.Currency == currency {
+			return balance
+		}
+	}
+	t.Fatalf("no %v balance", currency)
+	return Balance{}
+}
+
+func TestPaperClient_MarketOrder(t *testing.T) {
+	p := newTestPaperClient(t)
+
+	oid, err := p.PlaceOrder(&OrderPlacement{
+		Book:  *NewBook(BTC, MXN),
+		Side:  OrderSideBuy,
+		Type:  OrderTypeMarket,
+		Major: "0.3",
+	})
+	require.NoError(t, err)
+
+	// Waits for a snapshot.
+	order, err := p.LookupOrder(oid)
+	require.NoError(t, err)
+	assert.Equal(t, OrderStatusOpen, order.Status)
+
+	p.Feed(paperSnapshot(nil, [][2]Monetary{{"500000", "0.1"}, {"510000", "1"}}))
+
+	order, err = p.LookupOrder(oid)
+	require.NoError(t, err)
+	assert.Equal(t, OrderStatusCompleted, order.Status)
+
+	trades, err := p.OrderTrades(oid, nil)
+	require.NoError(t, err)
+	require.Len(t, trades, 2)
+	assert.Equal(t, Monetary("500000"), trades[0].Price)
+	assert.Equal(t, Monetary("0.1"), trades[0].Major)
+	assert.Equal(t, Monetary("510000"), trades[1].Price)
+	assert.Equal(t, Monetary("0.2"), trades[1].Major)
+	assert.Equal(t, Monetary("0.0013"), trades[1].FeesAmount)
+
+	// 1 + 0.3 - 0.3 * 0.0065
+	assert.Equal(t, Monetary("1.29805"), paperBalance(t, p, BTC).Total)
+	// 1000000 - 50000 - 102000
+	assert.Equal(t, Monetary("848000"), paperBalance(t, p, MXN).Total)
+
+	// The liquidity taken is gone until the next snapshot.
+	oid, err = p.PlaceOrder(&OrderPlacement{
+		Book:  *NewBook(BTC, MXN),
+		Side:  OrderSideBuy,
+		Type:  OrderTypeMarket,
+		Minor: "51000",
+	})
+	require.NoError(t, err)
+
+	trades, err = p.OrderTrades(oid, nil)
+	require.NoError(t, err)
+	require.Len(t, trades, 1)
+	assert.Equal(t, Monetary("510000"), trades[0].Price)
+	assert.Equal(t, Monetary("0.1"), trades[0].Major)
+}
+
+func TestPaperClient_DiffOrders(t *testing.T) {
+	p := newTestPaperClient(t)
+	p.Feed(paperSnapshot([][2]Monetary{{"499000", "1"}}, nil))
+
+	p.Feed(WebSocketDiffOrder{
+		Book: *NewBook(BTC, MXN),
+		Payload: []StreamOrderDiff{
+			{OrderID: "a1", Side: OrderSideSell, Price: "501000", Amount: "0.1", Status: OrderStatusOpen},
+			{OrderID: "a2", Side: OrderSideSell, Price: "500000", Amount: "0.1", Status: OrderStatusOpen},
+		},
+	})
+	p.Feed(WebSocketDiffOrder{
+		Book: *NewBook(BTC, MXN),
+		Payload: []StreamOrderDiff{
+			{OrderID: "a2", Side: OrderSideSell, Price: "500000", Status: OrderStatusCompleted},
+		},
+	})
+
+	oid, err := p.PlaceOrder(&OrderPlacement{
+		Book:  *NewBook(BTC, MXN),
+		Side:  OrderSideBuy,
+		Type:  OrderTypeMarket,
+		Major: "0.2",
+	})
+	require.NoError(t, err)
+
+	trades, err := p.OrderTrades(oid, nil)
+	require.NoError(t, err)
+	require.Len(t, trades, 1)
+	assert.Equal(t, Monetary("501000"), trades[0].Price)
+	assert.Equal(t, Monetary("0.1"), trades[0].Major)
+}
+
+func TestPaperClient_SnapshotAndDiffs(t *testing.T) {
+	p := newTestPaperClient(t)
+	p.Feed(paperSnapshot(nil, [][2]Monetary{{"500000", "0.1"}}))
+
+	// A diff of an order of the snapshot replaces it instead of adding to
+	// it.
+	p.Feed(WebSocketDiffOrder{
+		Book: *NewBook(BTC, MXN),
+		Payload: []StreamOrderDiff{
+			{OrderID: "a1", Side: OrderSideSell, Price: "500000", Amount: "0.05", Status: OrderStatusOpen},
+		},
+	})
+
+	oid, err := p.PlaceOrder(&OrderPlacement{
+		Book:  *NewBook(BTC, MXN),
+		Side:  OrderSideBuy,
+		Type:  OrderTypeMarket,
+		Major: "0.1",
+	})
+	require.NoError(t, err)
+
+	order, err := p.LookupOrder(oid)
+	require.NoError(t, err)
+	assert.Equal(t, OrderStatusCompleted, order.Status)
+	assert.Equal(t, Monetary("0.05"), order.OriginalAmount)
+}
+
+func TestPaperClient_RepeatedSnapshot(t *testing.T) {
+	p := newTestPaperClient(t)
+	snapshot := paperSnapshot(nil, [][2]Monetary{{"500000", "0.1"}})
+	p.Feed(snapshot)
+
+	oid, err := p.PlaceOrder(&OrderPlacement{
+		Book:  *NewBook(BTC, MXN),
+		Side:  OrderSideBuy,
+		Type:  OrderTypeLimit,
+		Price: "501000",
+		Major: "0.3",
+	})
+	require.NoError(t, err)
+
+	// The order the user took from is still listed, what was taken doesn't
+	// come back.
+	p.Feed(snapshot)
+	p.Feed(snapshot)
+	p.Feed(WebSocketDiffOrder{
+		Book: *NewBook(BTC, MXN),
+		Payload: []StreamOrderDiff{
+			{OrderID: "a1", Side: OrderSideSell, Price: "500000", Amount: "0.1", Status: OrderStatusOpen},
+		},
+	})
+
+	order, err := p.LookupOrder(oid)
+	require.NoError(t, err)
+	assert.Equal(t, OrderStatusPartialFill, order.Status)
+	assert.Equal(t, Monetary("0.2"), order.UnfilledAmount)
+
+	trades, err := p.OrderTrades(oid, nil)
+	require.NoError(t, err)
+	assert.Len(t, trades, 1)
+
+	// Once the order is gone, a new one with the same ID is new liquidity.
+	p.Feed(paperSnapshot(nil, nil))
+	p.Feed(snapshot)
+
+	order, err = p.LookupOrder(oid)
+	require.NoError(t, err)
+	assert.Equal(t, Monetary("0.1"), order.UnfilledAmount)
+}
+
+func TestPaperClient_LimitOrder(t *testing.T) {
+	p := newTestPaperClient(t)
+	p.Feed(paperSnapshot([][2]Monetary{{"499000", "1"}}, [][2]Monetary{{"501000", "1"}}))
+
+	oid, err := p.PlaceOrder(&OrderPlacement{
+		Book:     *NewBook(BTC, MXN),
+		Side:     OrderSideSell,
+		Type:     OrderTypeLimit,
+		Price:    "500000",
+		Major:    "0.5",
+		OriginID: "ask",
+	})
+	require.NoError(t, err)
+
+	balance := paperBalance(t, p, BTC)
+	assert.Equal(t, Monetary("0.5"), balance.Locked)
+	assert.Equal(t, Monetary("0.5"), balance.Available)
+
+	// Trades below our price don't reach us.
+	p.Feed(paperTrade(OrderSideSell, "499000", "1"))
+	// Neither do trades that hit the bids.
+	p.Feed(paperTrade(OrderSideBuy, "500500", "1"))
+	// Trades at or through our price do, up to the traded amount.
+	p.Feed(paperTrade(OrderSideSell, "500500", "0.2"))
+
+	orders, err := p.MyOpenOrders(nil)
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, OrderStatusPartialFill, orders[0].Status)
+	assert.Equal(t, Monetary("0.3"), orders[0].UnfilledAmount)
+
+	trades, err := p.MyTrades(nil)
+	require.NoError(t, err)
+	require.Len(t, trades, 1)
+	assert.Equal(t, oid, trades[0].OID)
+	assert.Equal(t, Monetary("500000"), trades[0].Price)
+	assert.Equal(t, Currency(MXN), trades[0].FeesCurrency)
+	// 0.2 * 500000 * 0.005
+	assert.Equal(t, Monetary("500"), trades[0].FeesAmount)
+
+	cancelled, err := p.CancelOrdersByOriginID([]string{"ask"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ask"}, cancelled)
+
+	balance = paperBalance(t, p, BTC)
+	assert.Equal(t, Monetary("0.8"), balance.Total)
+	assert.Equal(t, Monetary("0"), balance.Locked)
+	assert.Equal(t, Monetary("1099500"), paperBalance(t, p, MXN).Total)
+}
+
+func TestPaperClient_TimeInForce(t *testing.T) {
+	p := newTestPaperClient(t)
+	p.Feed(paperSnapshot(nil, [][2]Monetary{{"500000", "0.1"}}))
+
+	for _, tc := range []struct {
+		tif    TimeInForce
+		amount Monetary
+		status OrderStatus
+	}{
+		{TimeInForcePostOnly, "0.1", OrderStatusCancelled},
+		{TimeInForceFillOrKill, "0.2", OrderStatusCancelled},
+		{TimeInForceImmediateOrCancel, "0.05", OrderStatusCompleted},
+		{TimeInForceImmediateOrCancel, "0.1", OrderStatusCancelled},
+		{TimeInForceGoodTillCancelled, "0.1", OrderStatusOpen},
+	} {
+		oid, err := p.PlaceOrder(&OrderPlacement{
+			Book:        *NewBook(BTC, MXN),
+			Side:        OrderSideBuy,
+			Type:        OrderTypeLimit,
+			Price:       "500000",
+			Major:       tc.amount,
+			TimeInForce: tc.tif,
+		})
+		require.NoError(t, err)
+
+		order, err := p.LookupOrder(oid)
+		require.NoError(t, err)
+		assert.Equal(t, tc.status, order.Status, "%v %v", tc.tif, tc.amount)
+	}
+}
+
+func TestPaperClient_Errors(t *testing.T) {
+	p := newTestPaperClient(t)
+
+	_, err := p.PlaceOrder(&OrderPlacement{
+		Book:  *NewBook(BTC, MXN),
+		Side:  OrderSideBuy,
+		Type:  OrderTypeLimit,
+		Price: "500000",
+		Major: "3",
+	})
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	_, err = p.PlaceOrder(&OrderPlacement{
+		Book:  *NewBook(ETH, MXN),
+		Side:  OrderSideBuy,
+		Type:  OrderTypeLimit,
+		Price: "50000",
+		Major: "1",
+	})
+	assert.ErrorIs(t, err, ErrUnknownBook)
+
+	_, err = p.PlaceOrder(&OrderPlacement{
+		Book:  *NewBook(BTC, MXN),
+		Side:  OrderSideSell,
+		Type:  OrderTypeLimit,
+		Price: "500000",
+		Major: "0.00001",
+	})
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+
+	_, err = p.LookupOrder("unknown")
+	assert.ErrorIs(t, err, ErrOrderNotFound)
+
+	_, err = p.CancelBookOrders(nil)
+	assert.ErrorIs(t, err, errMissingBook)
+}
+
+func TestPaperClient_Pagination(t *testing.T) {
+	p := newTestPaperClient(t)
+
+	var oids []string
+	for _, price := range []Monetary{"400000", "410000", "420000"} {
+		oid, err := p.PlaceOrder(&OrderPlacement{
+			Book:  *NewBook(BTC, MXN),
+			Side:  OrderSideBuy,
+			Type:  OrderTypeLimit,
+			Price: price,
+			Major: "0.1",
+		})
+		require.NoError(t, err)
+		oids = append(oids, oid)
+	}
+
+	orders, err := p.MyOpenOrders(url.Values{"limit": {"2"}})
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	assert.Equal(t, oids[2], orders[0].OID)
+
+	orders, err = p.MyOpenOrders(url.Values{"limit": {"2"}, "marker": {orders[1].OID}})
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, oids[0], orders[0].OID)
+
+	cancelled, err := p.CancelAllOrders()
+	require.NoError(t, err)
+	assert.Len(t, cancelled, 3)
+}
+
+func TestPaperClient_Run(t *testing.T) {
+	server := newTestWebSocketServer(t)
+	defer server.Close()
+
+	ws, err := NewWebSocketConn(WithWebSocketURL(server.endpoint()), WithReconnectPolicy(nil))
+	require.NoError(t, err)
+	defer ws.Close()
+	conn := server.accept(t)
+
+	p := newTestPaperClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Run(ctx, ws)
+	}()
+	for i := 0; i < 3; i++ {
+		server.expectMessage(t)
+	}
+
+	_, err = p.PlaceOrder(&OrderPlacement{
+		Book:  *NewBook(BTC, MXN),
+		Side:  OrderSideSell,
+		Type:  OrderTypeMarket,
+		Major: "0.1",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type": "orders", "book": "btc_mxn", "payload": {"bids": [{"o": "b1", "r": "490000", "a": "1", "t": 0}], "asks": []}}`)))
+	require.Eventually(t, func() bool {
+		trades, _ := p.MyTrades(nil)
+		return len(trades) == 1
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestPaperClient_MatchingEnums(t *testing.T) {
+	// PaperClient converts between the enums of the package and the ones of
+	// the matching engine by value.
+	assert.Equal(t, OrderSideSell, OrderSide(matching.Sell))
+	assert.Equal(t, OrderTypeLimit, OrderType(matching.Limit))
+	assert.Equal(t, TimeInForcePostOnly, TimeInForce(matching.PostOnly))
+	assert.Equal(t, TimeInForceImmediateOrCancel, TimeInForce(matching.ImmediateOrCancel))
+	assert.Equal(t, OrderStatusPartialFill, OrderStatus(matching.PartialFill))
+	assert.Equal(t, OrderStatusCompleted, OrderStatus(matching.Completed))
+}
@@ -0,0 +1,313 @@
+package bitso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// queryDateFormat is the format of the start_date and end_date parameters.
+const queryDateFormat = "2006-01-02"
+
+// SortDirection tells in which order list endpoints return their items.
+type SortDirection uint8
+
+// List of sort directions.
+const (
+	SortNone SortDirection = iota
+
+	SortAsc
+	SortDesc
+)
+
+var sortDirections = map[SortDirection]string{
+	SortAsc:  "asc",
+	SortDesc: "desc",
+}
+
+func (s SortDirection) String() string {
+	if z, ok := sortDirections[s]; ok {
+		return z
+	}
+	return fmt.Sprintf("SortDirection(%d)", s)
+}
+
+// Pagination holds the parameters shared by all paginated endpoints.
+type Pagination struct {
+	// Return items that come after this marker (an ID from a previous page).
+	Marker string
+	// Sort direction, Bitso uses descending order by default.
+	Sort SortDirection
+	// Page size, between 1 and 100. Zero means Bitso's default.
+	Limit int
+}
+
+func (p Pagination) encode(v url.Values) error {
+	if p.Limit < 0 || p.Limit > maxPageSize {
+		return fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+	}
+	if p.Limit > 0 {
+		v.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Sort != SortNone {
+		if _, ok := sortDirections[p.Sort]; !ok {
+			return fmt.Errorf("unsupported sort direction %v", p.Sort)
+		}
+		v.Set("sort", p.Sort.String())
+	}
+	if p.Marker != "" {
+		v.Set("marker", p.Marker)
+	}
+	return nil
+}
+
+// DateRange limits results to the items created between two dates.
+type DateRange struct {
+	StartDate time.Time
+	EndDate   time.Time
+}
+
+func (r DateRange) encode(v url.Values) error {
+	if !r.StartDate.IsZero() && !r.EndDate.IsZero() && r.EndDate.Before(r.StartDate) {
+		return errors.New("end date must not be before start date")
+	}
+	if !r.StartDate.IsZero() {
+		v.Set("start_date", r.StartDate.Format(queryDateFormat))
+	}
+	if !r.EndDate.IsZero() {
+		v.Set("end_date", r.EndDate.Format(queryDateFormat))
+	}
+	return nil
+}
+
+// LedgerQuery represents the parameters accepted by /v3/ledger.
+type LedgerQuery struct {
+	Pagination
+	DateRange
+
+	// Only list operations of this type, if set.
+	Operation Operation
+}
+
+// Values validates the query and encodes it into URL parameters.
+func (q *LedgerQuery) Values() (url.Values, error) {
+	v := url.Values{}
+	if q.Operation != OperationNone {
+		if _, ok := operationNames[q.Operation]; !ok {
+			return nil, fmt.Errorf("unsupported operation %v", q.Operation)
+		}
+	}
+	if err := q.Pagination.encode(v); err != nil {
+		return nil, err
+	}
+	if err := q.DateRange.encode(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// TradesQuery represents the parameters accepted by /v3/trades.
+type TradesQuery struct {
+	Pagination
+
+	// Required.
+	Book *Book
+}
+
+// Values validates the query and encodes it into URL parameters.
+func (q *TradesQuery) Values() (url.Values, error) {
+	if q.Book == nil {
+		return nil, errors.New("book is required")
+	}
+	v := url.Values{"book": {q.Book.String()}}
+	if err := q.Pagination.encode(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// UserTradesQuery represents the parameters accepted by /v3/user_trades.
+type UserTradesQuery struct {
+	Pagination
+	DateRange
+
+	// Only list trades on this book, if set.
+	Book *Book
+}
+
+// Values validates the query and encodes it into URL parameters.
+func (q *UserTradesQuery) Values() (url.Values, error) {
+	v := url.Values{}
+	if q.Book != nil {
+		v.Set("book", q.Book.String())
+	}
+	if err := q.Pagination.encode(v); err != nil {
+		return nil, err
+	}
+	if err := q.DateRange.encode(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// OpenOrdersQuery represents the parameters accepted by /v3/open_orders.
+type OpenOrdersQuery struct {
+	Pagination
+
+	// Only list orders on this book, if set.
+	Book *Book
+}
+
+// Values validates the query and encodes it into URL parameters.
+func (q *OpenOrdersQuery) Values() (url.Values, error) {
+	v := url.Values{}
+	if q.Book != nil {
+		v.Set("book", q.Book.String())
+	}
+	if err := q.Pagination.encode(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// FundingsQuery represents the parameters accepted by /v3/fundings.
+type FundingsQuery struct {
+	Pagination
+
+	// Only list fundings with this status (e.g. "pending", "complete").
+	Status string
+	// Only list fundings made with this method (e.g. "btc", "sp").
+	Method string
+}
+
+// Values validates the query and encodes it into URL parameters.
+func (q *FundingsQuery) Values() (url.Values, error) {
+	v := url.Values{}
+	if q.Status != "" {
+		v.Set("status", q.Status)
+	}
+	if q.Method != "" {
+		v.Set("method", q.Method)
+	}
+	if err := q.Pagination.encode(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// WithdrawalsQuery represents the parameters accepted by /v3/withdrawals.
+type WithdrawalsQuery struct {
+	Pagination
+
+	// Only list withdrawals with this status (e.g. "pending", "complete").
+	Status string
+	// Only list withdrawals made with this method (e.g. "btc", "sp").
+	Method string
+}
+
+// Values validates the query and encodes it into URL parameters.
+func (q *WithdrawalsQuery) Values() (url.Values, error) {
+	v := url.Values{}
+	if q.Status != "" {
+		v.Set("status", q.Status)
+	}
+	if q.Method != "" {
+		v.Set("method", q.Method)
+	}
+	if err := q.Pagination.encode(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// OrderBookQuery represents the parameters accepted by /v3/order_book.
+type OrderBookQuery struct {
+	// Required.
+	Book *Book
+
+	// Return every single order instead of grouping them by price. Orders
+	// include their ID only when this is set.
+	Unaggregated bool
+}
+
+// Values validates the query and encodes it into URL parameters.
+func (q *OrderBookQuery) Values() (url.Values, error) {
+	if q.Book == nil {
+		return nil, errors.New("book is required")
+	}
+	v := url.Values{"book": {q.Book.String()}}
+	if q.Unaggregated {
+		v.Set("aggregate", "false")
+	}
+	return v, nil
+}
+
+// QueryLedger is like LedgerContext but takes typed parameters. If q sets an
+// operation, it is like LedgerByOperationContext.
+func (c *Client) QueryLedger(ctx context.Context, q *LedgerQuery) ([]Transaction, error) {
+	params, err := q.Values()
+	if err != nil {
+		return nil, err
+	}
+	if q.Operation != OperationNone {
+		return c.LedgerByOperationContext(ctx, q.Operation, params)
+	}
+	return c.LedgerContext(ctx, params)
+}
+
+// QueryTrades is like TradesContext but takes typed parameters.
+func (c *Client) QueryTrades(ctx context.Context, q *TradesQuery) ([]Trade, error) {
+	params, err := q.Values()
+	if err != nil {
+		return nil, err
+	}
+	return c.TradesContext(ctx, params)
+}
+
+// QueryMyTrades is like MyTradesContext but takes typed parameters.
+func (c *Client) QueryMyTrades(ctx context.Context, q *UserTradesQuery) ([]UserTrade, error) {
+	params, err := q.Values()
+	if err != nil {
+		return nil, err
+	}
+	return c.MyTradesContext(ctx, params)
+}
+
+// QueryMyOpenOrders is like MyOpenOrdersContext but takes typed parameters.
+func (c *Client) QueryMyOpenOrders(ctx context.Context, q *OpenOrdersQuery) ([]UserOrder, error) {
+	params, err := q.Values()
+	if err != nil {
+		return nil, err
+	}
+	return c.MyOpenOrdersContext(ctx, params)
+}
+
+// QueryFundings is like FundingsContext but takes typed parameters.
+func (c *Client) QueryFundings(ctx context.Context, q *FundingsQuery) ([]Funding, error) {
+	params, err := q.Values()
+	if err != nil {
+		return nil, err
+	}
+	return c.FundingsContext(ctx, params)
+}
+
+// QueryWithdrawals is like WithdrawalsContext but takes typed parameters.
+func (c *Client) QueryWithdrawals(ctx context.Context, q *WithdrawalsQuery) ([]Withdrawal, error) {
+	params, err := q.Values()
+	if err != nil {
+		return nil, err
+	}
+	return c.WithdrawalsContext(ctx, params)
+}
+
+// QueryOrderBook is like OrderBookContext but takes typed parameters.
+func (c *Client) QueryOrderBook(ctx context.Context, q *OrderBookQuery) (*OrderBook, error) {
+	params, err := q.Values()
+	if err != nil {
+		return nil, err
+	}
+	return c.OrderBookContext(ctx, params)
+}
@@ -0,0 +1,210 @@
+package bitso
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortDirection_String(t *testing.T) {
+	assert.Equal(t, "asc", SortAsc.String())
+	assert.Equal(t, "desc", SortDesc.String())
+	assert.Equal(t, "SortDirection(0)", SortNone.String())
+}
+
+func TestPagination_Encode(t *testing.T) {
+	t.Run("all fields", func(t *testing.T) {
+		v := url.Values{}
+		err := Pagination{Marker: "abc", Sort: SortAsc, Limit: 50}.encode(v)
+
+		require.NoError(t, err)
+		assert.Equal(t, url.Values{
+			"marker": {"abc"},
+			"sort":   {"asc"},
+			"limit":  {"50"},
+		}, v)
+	})
+
+	t.Run("zero value", func(t *testing.T) {
+		v := url.Values{}
+		require.NoError(t, Pagination{}.encode(v))
+		assert.Empty(t, v)
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		assert.Error(t, Pagination{Limit: -1}.encode(url.Values{}))
+		assert.Error(t, Pagination{Limit: maxPageSize + 1}.encode(url.Values{}))
+	})
+
+	t.Run("invalid sort", func(t *testing.T) {
+		assert.Error(t, Pagination{Sort: SortDirection(9)}.encode(url.Values{}))
+	})
+}
+
+func TestDateRange_Encode(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	v := url.Values{}
+	require.NoError(t, DateRange{StartDate: start, EndDate: end}.encode(v))
+	assert.Equal(t, "2024-01-01", v.Get("start_date"))
+	assert.Equal(t, "2024-01-31", v.Get("end_date"))
+
+	assert.Error(t, DateRange{StartDate: end, EndDate: start}.encode(url.Values{}))
+}
+
+func TestQueryValues(t *testing.T) {
+	book := NewBook(BTC, MXN)
+
+	tests := []struct {
+		name     string
+		query    interface{ Values() (url.Values, error) }
+		expected url.Values
+	}{
+		{
+			"ledger",
+			&LedgerQuery{Pagination: Pagination{Limit: 10}, Operation: OperationTrade},
+			url.Values{"limit": {"10"}},
+		},
+		{
+			"trades",
+			&TradesQuery{Book: book, Pagination: Pagination{Sort: SortDesc}},
+			url.Values{"book": {"btc_mxn"}, "sort": {"desc"}},
+		},
+		{
+			"user trades",
+			&UserTradesQuery{Book: book, DateRange: DateRange{StartDate: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)}},
+			url.Values{"book": {"btc_mxn"}, "start_date": {"2024-02-01"}},
+		},
+		{
+			"open orders",
+			&OpenOrdersQuery{},
+			url.Values{},
+		},
+		{
+			"fundings",
+			&FundingsQuery{Status: "complete", Method: "sp"},
+			url.Values{"status": {"complete"}, "method": {"sp"}},
+		},
+		{
+			"withdrawals",
+			&WithdrawalsQuery{Status: "pending", Pagination: Pagination{Marker: "w1"}},
+			url.Values{"status": {"pending"}, "marker": {"w1"}},
+		},
+		{
+			"order book",
+			&OrderBookQuery{Book: book, Unaggregated: true},
+			url.Values{"book": {"btc_mxn"}, "aggregate": {"false"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := tc.query.Values()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, v)
+		})
+	}
+}
+
+func TestQueryValues_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		query interface{ Values() (url.Values, error) }
+	}{
+		{"trades without book", &TradesQuery{}},
+		{"order book without book", &OrderBookQuery{}},
+		{"ledger with unknown operation", &LedgerQuery{Operation: Operation(42)}},
+		{"user trades with reversed dates", &UserTradesQuery{DateRange: DateRange{
+			StartDate: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		}}},
+		{"open orders with large limit", &OpenOrdersQuery{Pagination: Pagination{Limit: 1000}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.query.Values()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestQueryLedger(t *testing.T) {
+	t.Run("by operation", func(t *testing.T) {
+		server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+			assert.True(t, strings.HasSuffix(r.URL.Path, "/ledger/fundings"))
+			assert.Equal(t, "5", r.URL.Query().Get("limit"))
+			w.Write(successResponse([]interface{}{}))
+		})
+		defer server.Close()
+
+		client.SetAuth("test-key", "test-secret")
+		_, err := client.QueryLedger(context.Background(), &LedgerQuery{
+			Operation:  OperationFunding,
+			Pagination: Pagination{Limit: 5},
+		})
+
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid query is not sent", func(t *testing.T) {
+		server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("request should not reach the server")
+		})
+		defer server.Close()
+
+		_, err := client.QueryLedger(context.Background(), &LedgerQuery{Pagination: Pagination{Limit: -5}})
+
+		require.Error(t, err)
+	})
+}
+
+func TestQueryOrderBook(t *testing.T) {
+	server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "btc_mxn", r.URL.Query().Get("book"))
+		assert.Equal(t, "false", r.URL.Query().Get("aggregate"))
+
+		payload := map[string]interface{}{
+			"asks": []map[string]interface{}{
+				{"book": "btc_mxn", "price": "500000.00", "amount": "0.5", "oid": "ask-1"},
+			},
+			"bids":       []interface{}{},
+			"updated_at": "2024-01-15T10:30:00+00:00",
+			"sequence":   "27214",
+		}
+		w.Write(successResponse(payload))
+	})
+	defer server.Close()
+
+	orderBook, err := client.QueryOrderBook(context.Background(), &OrderBookQuery{
+		Book:         NewBook(BTC, MXN),
+		Unaggregated: true,
+	})
+
+	require.NoError(t, err)
+	require.Len(t, orderBook.Asks, 1)
+	assert.Equal(t, "ask-1", orderBook.Asks[0].OID)
+}
+
+func TestQueryTrades(t *testing.T) {
+	server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "eth_mxn", r.URL.Query().Get("book"))
+		assert.Equal(t, "asc", r.URL.Query().Get("sort"))
+		w.Write(successResponse([]interface{}{}))
+	})
+	defer server.Close()
+
+	_, err := client.QueryTrades(context.Background(), &TradesQuery{
+		Book:       NewBook(ETH, MXN),
+		Pagination: Pagination{Sort: SortAsc},
+	})
+
+	require.NoError(t, err)
+}
@@ -0,0 +1,213 @@
+package bitso
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default request limits enforced by Bitso, per minute.
+const (
+	DefaultPublicRateLimit  = 60
+	DefaultPrivateRateLimit = 300
+)
+
+// defaultRateLimitPause is how long requests are held after a 429 response
+// that does not include a Retry-After header.
+const defaultRateLimitPause = time.Minute
+
+// RateLimitScope tells which of Bitso's limits a request counts against.
+type RateLimitScope uint8
+
+// List of rate limit scopes.
+const (
+	RateLimitPublic RateLimitScope = iota
+	RateLimitPrivate
+)
+
+var rateLimitScopes = map[RateLimitScope]string{
+	RateLimitPublic:  "public",
+	RateLimitPrivate: "private",
+}
+
+func (s RateLimitScope) String() string {
+	if z, ok := rateLimitScopes[s]; ok {
+		return z
+	}
+	return "RateLimitScope(" + strconv.Itoa(int(s)) + ")"
+}
+
+// publicEndpoints lists the endpoints that do not require authentication.
+var publicEndpoints = []string{
+	"/available_books",
+	"/ticker",
+	"/order_book",
+	"/trades",
+}
+
+func endpointScope(endpoint string) RateLimitScope {
+	for _, prefix := range publicEndpoints {
+		if endpoint == prefix || strings.HasPrefix(endpoint, prefix+"/") {
+			return RateLimitPublic
+		}
+	}
+	return RateLimitPrivate
+}
+
+// A RateLimiter decides when the client is allowed to send a request.
+type RateLimiter interface {
+	// Wait blocks until a request in the given scope can be sent, or until ctx
+	// is done.
+	Wait(ctx context.Context, scope RateLimitScope) error
+
+	// Pause holds all requests in the given scope for the given duration. The
+	// client calls Pause when Bitso responds with 429 Too Many Requests.
+	Pause(scope RateLimitScope, d time.Duration)
+}
+
+// SetRateLimiter sets the rate limiter used by the client. A nil limiter
+// disables rate limiting.
+func (c *Client) SetRateLimiter(limiter RateLimiter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.limiter = limiter
+}
+
+// RateLimiter returns the current rate limiter.
+func (c *Client) RateLimiter() RateLimiter {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.limiter
+}
+
+// retryAfter returns the delay requested by the server in a Retry-After
+// header, or the given fallback.
+func retryAfter(res *http.Response, fallback time.Duration) time.Duration {
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return fallback
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return fallback
+}
+
+// A TokenBucketLimiter is a RateLimiter that keeps a token bucket for each
+// scope. Every request takes a token, tokens are refilled continuously.
+type TokenBucketLimiter struct {
+	buckets map[RateLimitScope]*tokenBucket
+}
+
+// NewTokenBucketLimiter creates a rate limiter that allows the given number of
+// public and private requests per minute. A non-positive limit means the scope
+// is not limited.
+func NewTokenBucketLimiter(publicPerMinute, privatePerMinute int) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		buckets: map[RateLimitScope]*tokenBucket{
+			RateLimitPublic:  newTokenBucket(publicPerMinute, time.Minute),
+			RateLimitPrivate: newTokenBucket(privatePerMinute, time.Minute),
+		},
+	}
+}
+
+// NewDefaultRateLimiter creates a rate limiter that follows Bitso's
+// documented limits.
+func NewDefaultRateLimiter() *TokenBucketLimiter {
+	return NewTokenBucketLimiter(DefaultPublicRateLimit, DefaultPrivateRateLimit)
+}
+
+// Wait implements RateLimiter.
+func (l *TokenBucketLimiter) Wait(ctx context.Context, scope RateLimitScope) error {
+	return l.buckets[scope].wait(ctx)
+}
+
+// Pause implements RateLimiter.
+func (l *TokenBucketLimiter) Pause(scope RateLimitScope, d time.Duration) {
+	l.buckets[scope].pause(d)
+}
+
+type tokenBucket struct {
+	capacity float64
+	// Tokens added per second.
+	rate float64
+
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+
+	mu sync.Mutex
+}
+
+// newTokenBucket creates a bucket that holds up to n tokens and refills n
+// tokens per interval. A nil bucket never blocks.
+func newTokenBucket(n int, interval time.Duration) *tokenBucket {
+	if n <= 0 || interval <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		capacity: float64(n),
+		rate:     float64(n) / interval.Seconds(),
+		tokens:   float64(n),
+		last:     time.Now(),
+	}
+}
+
+// reserve takes a token if one is available, otherwise it returns how long
+// to wait before trying again.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if now.Before(b.pausedUntil) {
+		return b.pausedUntil.Sub(now)
+	}
+
+	b.tokens = min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil || b == nil {
+		return err
+	}
+	for {
+		wait := b.reserve(time.Now())
+		if wait <= 0 {
+			return nil
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+func (b *tokenBucket) pause(d time.Duration) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(b.pausedUntil) {
+		b.pausedUntil = until
+	}
+	// Start over with an empty bucket once the pause is over.
+	b.tokens = 0
+	b.last = b.pausedUntil
+}
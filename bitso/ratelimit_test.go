@@ -0,0 +1,237 @@
+package bitso
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingLimiter struct {
+	waits  map[RateLimitScope]int
+	pauses map[RateLimitScope]time.Duration
+}
+
+func newRecordingLimiter() *recordingLimiter {
+	return &recordingLimiter{
+		waits:  map[RateLimitScope]int{},
+		pauses: map[RateLimitScope]time.Duration{},
+	}
+}
+
+func (l *recordingLimiter) Wait(ctx context.Context, scope RateLimitScope) error {
+	l.waits[scope]++
+	return ctx.Err()
+}
+
+func (l *recordingLimiter) Pause(scope RateLimitScope, d time.Duration) {
+	l.pauses[scope] = d
+}
+
+func TestEndpointScope(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		expected RateLimitScope
+	}{
+		{"/available_books", RateLimitPublic},
+		{"/ticker", RateLimitPublic},
+		{"/order_book", RateLimitPublic},
+		{"/trades", RateLimitPublic},
+		{"/balance", RateLimitPrivate},
+		{"/ledger/trades", RateLimitPrivate},
+		{"/orders/", RateLimitPrivate},
+		{"/open_orders", RateLimitPrivate},
+		{"/user_trades", RateLimitPrivate},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.endpoint, func(t *testing.T) {
+			assert.Equal(t, tc.expected, endpointScope(tc.endpoint))
+		})
+	}
+}
+
+func TestRateLimitScope_String(t *testing.T) {
+	assert.Equal(t, "public", RateLimitPublic.String())
+	assert.Equal(t, "private", RateLimitPrivate.String())
+	assert.Equal(t, "RateLimitScope(9)", RateLimitScope(9).String())
+}
+
+func TestTokenBucket(t *testing.T) {
+	t.Run("allows bursts up to capacity", func(t *testing.T) {
+		b := newTokenBucket(3, time.Minute)
+		now := time.Now()
+
+		assert.Zero(t, b.reserve(now))
+		assert.Zero(t, b.reserve(now))
+		assert.Zero(t, b.reserve(now))
+		assert.InDelta(t, float64(20*time.Second), float64(b.reserve(now)), float64(time.Millisecond))
+	})
+
+	t.Run("refills over time", func(t *testing.T) {
+		b := newTokenBucket(60, time.Minute)
+		now := time.Now()
+
+		for i := 0; i < 60; i++ {
+			require.Zero(t, b.reserve(now))
+		}
+		assert.NotZero(t, b.reserve(now))
+		assert.Zero(t, b.reserve(now.Add(time.Second)))
+	})
+
+	t.Run("pause holds requests", func(t *testing.T) {
+		b := newTokenBucket(60, time.Minute)
+		b.pause(time.Hour)
+
+		assert.Greater(t, b.reserve(time.Now()), 59*time.Minute)
+	})
+
+	t.Run("nil bucket never blocks", func(t *testing.T) {
+		b := newTokenBucket(0, time.Minute)
+		require.Nil(t, b)
+
+		assert.NoError(t, b.wait(context.Background()))
+		b.pause(time.Hour)
+	})
+
+	t.Run("wait honors context", func(t *testing.T) {
+		b := newTokenBucket(1, time.Hour)
+		require.NoError(t, b.wait(context.Background()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		assert.ErrorIs(t, b.wait(ctx), context.DeadlineExceeded)
+	})
+}
+
+func TestTokenBucketLimiter_Scopes(t *testing.T) {
+	l := NewTokenBucketLimiter(1, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	require.NoError(t, l.Wait(ctx, RateLimitPublic))
+	assert.ErrorIs(t, l.Wait(ctx, RateLimitPublic), context.DeadlineExceeded)
+
+	// Private requests are not limited.
+	assert.NoError(t, l.Wait(context.Background(), RateLimitPrivate))
+}
+
+func TestClientRateLimiter(t *testing.T) {
+	server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(successResponse([]interface{}{}))
+	})
+	defer server.Close()
+
+	limiter := newRecordingLimiter()
+	client.SetRateLimiter(limiter)
+	assert.Equal(t, limiter, client.RateLimiter())
+
+	client.SetAuth("test-key", "test-secret")
+
+	_, err := client.AvailableBooks()
+	require.NoError(t, err)
+	_, err = client.Ledger(nil)
+	require.NoError(t, err)
+	_, err = client.MyTrades(nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, limiter.waits[RateLimitPublic])
+	assert.Equal(t, 2, limiter.waits[RateLimitPrivate])
+}
+
+func TestClientRateLimiter_TooManyRequests(t *testing.T) {
+	t.Run("retry-after header", func(t *testing.T) {
+		server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+		defer server.Close()
+
+		limiter := newRecordingLimiter()
+		client.SetRateLimiter(limiter)
+
+		_, err := client.Trades(nil)
+
+		require.Error(t, err)
+		assert.Equal(t, 7*time.Second, limiter.pauses[RateLimitPublic])
+	})
+
+	t.Run("default pause", func(t *testing.T) {
+		server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+		defer server.Close()
+
+		limiter := newRecordingLimiter()
+		client.SetRateLimiter(limiter)
+		client.SetAuth("test-key", "test-secret")
+
+		_, err := client.Balances(nil)
+
+		require.Error(t, err)
+		assert.Equal(t, defaultRateLimitPause, limiter.pauses[RateLimitPrivate])
+	})
+
+	t.Run("requests wait for the pause", func(t *testing.T) {
+		var calls int32
+		server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.Header().Set("Retry-After", "3600")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Write(successResponse([]interface{}{}))
+		})
+		defer server.Close()
+
+		client.SetRateLimiter(NewDefaultRateLimiter())
+
+		_, err := client.AvailableBooks()
+		require.Error(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err = client.AvailableBooksContext(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestSetBurstRate_RateLimiter(t *testing.T) {
+	server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(successResponse([]interface{}{}))
+	})
+	defer server.Close()
+
+	// The burst rate doesn't replace the rate limiter, whatever the order
+	// they're set in.
+	before := newRecordingLimiter()
+	client.SetRateLimiter(before)
+	client.SetBurstRate(time.Hour)
+	assert.Equal(t, before, client.RateLimiter())
+
+	_, err := client.AvailableBooks()
+	require.NoError(t, err)
+	assert.Equal(t, 1, before.waits[RateLimitPublic])
+
+	after := newRecordingLimiter()
+	client.SetRateLimiter(after)
+	assert.Equal(t, time.Hour, client.BurstRate())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.AvailableBooksContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	client.SetBurstRate(0)
+	_, err = client.AvailableBooks()
+	require.NoError(t, err)
+	assert.Equal(t, 1, after.waits[RateLimitPublic])
+}
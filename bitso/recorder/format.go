@@ -0,0 +1,111 @@
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xiam/bitso-go/bitso"
+)
+
+// maxLineSize is the largest recorded line a FileReader accepts.
+const maxLineSize = 16 << 20
+
+// record is a line of a recorded file.
+type record struct {
+	ReceivedAt time.Time       `json:"received_at"`
+	Frame      json.RawMessage `json:"frame"`
+}
+
+// An Event is a recorded market data message and the time it was received.
+type Event struct {
+	Time time.Time
+	// Message is what bitso.DecodeWebSocketMessage returned for the frame,
+	// usually a bitso.WebSocketTrade, bitso.WebSocketDiffOrder or
+	// bitso.WebSocketOrder.
+	Message interface{}
+}
+
+// A FileReader reads events from a single recorded file: JSON lines holding
+// the receive time and the websocket frame, like
+//
+//	{"received_at": "2024-01-15T10:30:00.123Z", "frame": {"type": "trades", ...}}
+type FileReader struct {
+	scanner *bufio.Scanner
+	line    int
+	closers []io.Closer
+}
+
+// NewFileReader creates a FileReader that reads recorded lines from r.
+func NewFileReader(r io.Reader) *FileReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxLineSize)
+	return &FileReader{scanner: scanner}
+}
+
+// OpenFile opens a recorded file for reading, files whose name ends in ".gz"
+// are decompressed.
+func OpenFile(name string) (*FileReader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(name, ".gz") {
+		r := NewFileReader(f)
+		r.closers = []io.Closer{f}
+		return r, nil
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	r := NewFileReader(gz)
+	r.closers = []io.Closer{gz, f}
+	return r, nil
+}
+
+// Next returns the next event, or io.EOF after the last one. Frames that can
+// not be decoded are reported as errors along with their line number.
+func (r *FileReader) Next() (Event, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := r.scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return Event{}, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		msg, err := bitso.DecodeWebSocketMessage(rec.Frame)
+		if err != nil {
+			return Event{}, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		return Event{Time: rec.ReceivedAt, Message: msg}, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
+
+// Close closes the file opened by OpenFile, it does nothing for readers
+// created with NewFileReader.
+func (r *FileReader) Close() error {
+	var firstErr error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
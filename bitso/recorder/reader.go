@@ -0,0 +1,71 @@
+package recorder
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// Files returns the files recorded in dir with the given prefix, oldest
+// first.
+func Files(dir, prefix string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, prefix+"-*"+fileExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// A Reader replays recorded files one after the other.
+type Reader struct {
+	files   []string
+	name    string
+	current *FileReader
+}
+
+// NewReader creates a Reader for the given files, which are read in order.
+func NewReader(files ...string) *Reader {
+	return &Reader{files: files}
+}
+
+// Next returns the next recorded frame, decoded into the same type a
+// WebSocketConn would deliver, and the time it was received. It returns
+// io.EOF after the last file.
+func (r *Reader) Next() (Event, error) {
+	for {
+		if r.current == nil {
+			if len(r.files) == 0 {
+				return Event{}, io.EOF
+			}
+			current, err := OpenFile(r.files[0])
+			if err != nil {
+				return Event{}, err
+			}
+			r.current, r.name, r.files = current, r.files[0], r.files[1:]
+		}
+
+		event, err := r.current.Next()
+		if err == nil {
+			return event, nil
+		}
+		if err != io.EOF {
+			return Event{}, fmt.Errorf("%s: %w", r.name, err)
+		}
+		if err := r.current.Close(); err != nil {
+			return Event{}, err
+		}
+		r.current = nil
+	}
+}
+
+// Close closes the file being read.
+func (r *Reader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
@@ -0,0 +1,65 @@
+// Package recorder saves the market data received from Bitso's websocket API
+// to disk, and reads it back for research and backtesting.
+package recorder
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/xiam/bitso-go/bitso"
+)
+
+// Channels are the channels recorded for each book.
+var Channels = []string{"trades", "diff-orders", "orders"}
+
+var errConnectionClosed = errors.New("websocket connection closed")
+
+// Record connects to Bitso's websocket API, subscribes to Channels for every
+// book and writes each frame received, replies and keep alive messages
+// included, to w. Frames that are not JSON are skipped.
+//
+// Record blocks until ctx is done, the connection is closed for good or a
+// frame can not be written. It does not close w.
+func Record(ctx context.Context, w *Writer, books []bitso.Book, opts ...bitso.WebSocketOption) error {
+	failed := make(chan error, 1)
+	handler := func(receivedAt time.Time, frame []byte) {
+		err := w.Write(receivedAt, frame)
+		if err == nil || errors.Is(err, errInvalidFrame) {
+			return
+		}
+		select {
+		case failed <- err:
+		default:
+		}
+	}
+
+	opts = append(opts[:len(opts):len(opts)], bitso.WithFrameHandler(handler))
+	ws, err := bitso.NewWebSocketConnContext(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+
+	for _, book := range books {
+		for _, channel := range Channels {
+			if err := ws.Subscribe(&book, channel); err != nil {
+				return err
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-failed:
+			return err
+		case _, ok := <-ws.Receive():
+			// Frames were already written, decoded messages are not needed.
+			if !ok {
+				return errConnectionClosed
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package recorder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xiam/bitso-go/bitso"
+	"github.com/xiam/bitso-go/bitso/bitsotest"
+)
+
+var (
+	btcMXN = *bitso.NewBook(bitso.BTC, bitso.MXN)
+	start  = time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+)
+
+func readAll(t *testing.T, files []string) []Event {
+	t.Helper()
+
+	r := NewReader(files...)
+	defer r.Close()
+
+	var events []Event
+	for {
+		event, err := r.Next()
+		if err == io.EOF {
+			return events
+		}
+		require.NoError(t, err)
+		events = append(events, event)
+	}
+}
+
+func TestWriter_Rotate(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewWriter(dir, WithPrefix("test"), WithRotateInterval(time.Minute))
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		at := start.Add(time.Duration(i) * 40 * time.Second)
+		require.NoError(t, w.Write(at, []byte(`{"type": "ka"}`)))
+	}
+	assert.ErrorIs(t, w.Write(start, []byte(`not json`)), errInvalidFrame)
+	require.NoError(t, w.Close())
+
+	files, err := Files(dir, "test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "test-20240115T103000Z-0000.jsonl.gz"),
+		filepath.Join(dir, "test-20240115T103120Z-0000.jsonl.gz"),
+		filepath.Join(dir, "test-20240115T103200Z-0000.jsonl.gz"),
+	}, files)
+
+	events := readAll(t, files)
+	require.Len(t, events, 4)
+	for i, event := range events {
+		assert.Equal(t, start.Add(time.Duration(i)*40*time.Second), event.Time)
+		assert.Equal(t, bitso.WebSocketReply{Type: "ka"}, event.Message)
+	}
+}
+
+func TestWriter_MaxFileSize(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewWriter(dir, WithMaxFileSize(100))
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, w.Write(start, []byte(`{"type": "ka"}`)))
+	}
+	require.NoError(t, w.Close())
+
+	files, err := Files(dir, "bitso")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "bitso-20240115T103000Z-0000.jsonl.gz"),
+		filepath.Join(dir, "bitso-20240115T103000Z-0001.jsonl.gz"),
+		filepath.Join(dir, "bitso-20240115T103000Z-0002.jsonl.gz"),
+	}, files)
+	assert.Len(t, readAll(t, files), 3)
+}
+
+func TestOpenFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "btc_mxn.jsonl.gz")
+
+	f, err := os.Create(name)
+	require.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	_, err = gz.Write([]byte(strings.Join([]string{
+		`{"received_at": "2024-01-15T10:30:00.5Z", "frame": {"type": "ka"}}`,
+		``,
+		`{"received_at": "2024-01-15T10:30:01Z", "frame": {"type": "trades", "book": "btc_mxn", "payload": [{"i": 1, "a": "0.1", "r": "500000", "v": "50000", "t": 0, "x": 1705314601000}]}}`,
+		`{"received_at": "2024-01-15T10:30:02Z", "frame": {"type": "trades", "book": "btc_mxn", "payload": [{"i": "bad"}]}}`,
+	}, "\n")))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, f.Close())
+
+	r, err := OpenFile(name)
+	require.NoError(t, err)
+	defer r.Close()
+
+	event, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, start.Add(500*time.Millisecond), event.Time)
+	assert.Equal(t, bitso.WebSocketReply{Type: "ka"}, event.Message)
+
+	event, err = r.Next()
+	require.NoError(t, err)
+	msg, ok := event.Message.(bitso.WebSocketTrade)
+	require.True(t, ok)
+	assert.Equal(t, btcMXN, msg.Book)
+	assert.Equal(t, bitso.Monetary("500000"), msg.Payload[0].Price)
+
+	_, err = r.Next()
+	assert.ErrorContains(t, err, "line 4")
+}
+
+func TestRecord(t *testing.T) {
+	server := bitsotest.NewWebSocketServer()
+	defer server.Close()
+
+	dir := t.TempDir()
+	w, err := NewWriter(dir)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Record(ctx, w, []bitso.Book{btcMXN},
+			bitso.WithWebSocketURL(server.URL),
+			bitso.WithWebSocketLogger(zerolog.Nop()),
+		)
+	}()
+	for _, channel := range Channels {
+		require.NoError(t, server.WaitSubscribed(ctx, channel, btcMXN))
+	}
+
+	require.NoError(t, server.PushTrades(btcMXN, bitso.StreamTrade{TID: 1, Amount: "0.1", Price: "500000", CreatedAt: start}))
+	require.NoError(t, server.PushDiffOrders(btcMXN, 1, bitso.StreamOrderDiff{OrderID: "o1", Price: "499000", Amount: "1", Status: bitso.OrderStatusOpen}))
+	require.NoError(t, server.PushOrders(btcMXN, 2, []bitso.StreamOrder{{OrderID: "o1", Price: "499000", Amount: "1"}}, nil))
+	require.NoError(t, server.PushRaw([]byte(`{"type": "ka"}`)))
+
+	// The keep alive message is the last one, wait for it to be written.
+	require.Eventually(t, func() bool {
+		require.NoError(t, w.Flush())
+		files, err := Files(dir, "bitso")
+		if err != nil || len(files) != 1 {
+			return false
+		}
+		f, err := os.Open(files[0])
+		if err != nil {
+			return false
+		}
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return false
+		}
+		// The file is not closed yet, the error at its end is expected.
+		data, _ := io.ReadAll(gz)
+		return bytes.Contains(data, []byte(`"ka"`))
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	require.NoError(t, w.Close())
+
+	files, err := Files(dir, "bitso")
+	require.NoError(t, err)
+	events := readAll(t, files)
+
+	var trades, diffs, orders, replies int
+	for _, event := range events {
+		assert.False(t, event.Time.IsZero())
+		switch msg := event.Message.(type) {
+		case bitso.WebSocketTrade:
+			trades++
+			assert.Equal(t, bitso.TID(1), msg.Payload[0].TID)
+		case bitso.WebSocketDiffOrder:
+			diffs++
+			assert.Equal(t, uint64(1), msg.Sequence)
+		case bitso.WebSocketOrder:
+			orders++
+			assert.Equal(t, "o1", msg.Payload.Bids[0].OrderID)
+		case bitso.WebSocketReply:
+			replies++
+		}
+	}
+	assert.Equal(t, 1, trades)
+	assert.Equal(t, 1, diffs)
+	assert.Equal(t, 1, orders)
+	// Three subscription replies and the keep alive.
+	assert.Equal(t, 4, replies)
+}
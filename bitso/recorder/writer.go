@@ -0,0 +1,182 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Defaults of a Writer.
+const (
+	defaultPrefix         = "bitso"
+	defaultRotateInterval = time.Hour
+)
+
+// fileExt is the extension of recorded files.
+const fileExt = ".jsonl.gz"
+
+var errInvalidFrame = errors.New("frame is not valid JSON")
+
+// An Option configures a Writer.
+type Option func(*Writer)
+
+// WithPrefix sets the prefix of the names of the files, "bitso" by default.
+func WithPrefix(prefix string) Option {
+	return func(w *Writer) {
+		w.prefix = prefix
+	}
+}
+
+// WithRotateInterval sets how much time each file covers, one hour by
+// default. Files start at multiples of the interval, in UTC.
+func WithRotateInterval(interval time.Duration) Option {
+	return func(w *Writer) {
+		w.interval = interval
+	}
+}
+
+// WithMaxFileSize makes the Writer start a new file when the current one
+// holds size bytes of uncompressed data. There is no limit by default.
+func WithMaxFileSize(size int64) Option {
+	return func(w *Writer) {
+		w.maxSize = size
+	}
+}
+
+// A Writer writes frames to gzip compressed JSON lines files in a directory,
+// starting a new file every interval and, optionally, when a file gets too
+// big. Files are named after the time of their first frame, as in
+// "bitso-20240115T100000Z-0000.jsonl.gz", so they sort by time.
+//
+// A Writer is safe for concurrent use.
+type Writer struct {
+	dir      string
+	prefix   string
+	interval time.Duration
+	maxSize  int64
+
+	file   *os.File
+	gz     *gzip.Writer
+	buf    *bufio.Writer
+	period time.Time
+	size   int64
+
+	mu sync.Mutex
+}
+
+// NewWriter creates a Writer that writes files in dir, which is created if
+// needed.
+func NewWriter(dir string, opts ...Option) (*Writer, error) {
+	w := &Writer{
+		dir:      dir,
+		prefix:   defaultPrefix,
+		interval: defaultRotateInterval,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.interval <= 0 {
+		return nil, fmt.Errorf("invalid rotate interval %v", w.interval)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write appends a frame received at receivedAt to the current file. Frames
+// must be JSON documents, as sent by Bitso.
+func (w *Writer) Write(receivedAt time.Time, frame []byte) error {
+	if !json.Valid(frame) {
+		return errInvalidFrame
+	}
+	line, err := json.Marshal(record{ReceivedAt: receivedAt.UTC(), Frame: frame})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	period := receivedAt.UTC().Truncate(w.interval)
+	if w.file != nil && (!period.Equal(w.period) || (w.maxSize > 0 && w.size+int64(len(line)) > w.maxSize)) {
+		if err := w.closeFile(); err != nil {
+			return err
+		}
+	}
+	if w.file == nil {
+		if err := w.openFile(receivedAt.UTC()); err != nil {
+			return err
+		}
+		w.period = period
+	}
+
+	n, err := w.buf.Write(line)
+	w.size += int64(n)
+	return err
+}
+
+// Flush writes buffered data to the current file. Data is only readable
+// after the file is closed, Flush makes less data be lost on a crash.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	return w.gz.Flush()
+}
+
+// Close closes the current file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	return w.closeFile()
+}
+
+func (w *Writer) openFile(t time.Time) error {
+	stamp := t.Format("20060102T150405Z")
+	for seq := 0; ; seq++ {
+		name := filepath.Join(w.dir, fmt.Sprintf("%s-%s-%04d%s", w.prefix, stamp, seq, fileExt))
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		w.file = f
+		w.gz = gzip.NewWriter(f)
+		w.buf = bufio.NewWriter(w.gz)
+		w.size = 0
+		return nil
+	}
+}
+
+func (w *Writer) closeFile() error {
+	err := w.buf.Flush()
+	if gzErr := w.gz.Close(); err == nil {
+		err = gzErr
+	}
+	if fileErr := w.file.Close(); err == nil {
+		err = fileErr
+	}
+	w.file, w.gz, w.buf = nil, nil, nil
+	return err
+}
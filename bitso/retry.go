@@ -0,0 +1,137 @@
+package bitso
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// A RetryPolicy tells the client when and how often a failed request should
+// be retried.
+//
+// Only idempotent requests are retried: GET and DELETE requests, and order
+// placements that carry an OriginID. Every attempt is signed again, so each
+// one uses a fresh nonce.
+type RetryPolicy struct {
+	// Maximum number of attempts, including the first one.
+	MaxAttempts int
+
+	// Delay before the first retry, it doubles on every subsequent attempt.
+	MinBackoff time.Duration
+	// Upper bound for the delay between attempts.
+	MaxBackoff time.Duration
+	// Fraction of the delay (between 0 and 1) that is randomized.
+	Jitter float64
+
+	// HTTP status codes that can be retried.
+	RetryStatuses []int
+	// Bitso API error codes that can be retried.
+	RetryCodes []int
+}
+
+// DefaultRetryPolicy returns a retry policy that retries transport errors,
+// rate-limited and server-side failures up to three times.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  250 * time.Millisecond,
+		MaxBackoff:  5 * time.Second,
+		Jitter:      0.5,
+		RetryStatuses: []int{
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		RetryCodes: []int{
+			// Invalid nonce, a request signed with credentials that are
+			// actually wrong fails again after MaxAttempts.
+			201,
+		},
+	}
+}
+
+// SetRetryPolicy sets the policy used to retry failed requests. A nil policy
+// disables retries.
+func (c *Client) SetRetryPolicy(policy *RetryPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.retryPolicy = policy
+}
+
+// RetryPolicy returns the current retry policy.
+func (c *Client) RetryPolicy() *RetryPolicy {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.retryPolicy
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// retryable tells whether a request that failed with the given HTTP status
+// and error can be attempted again.
+func (p *RetryPolicy) retryable(status int, err error) bool {
+	if p == nil || err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if status == 0 {
+		// Only transport failures are worth retrying when there is no response.
+		var urlErr *url.Error
+		return errors.As(err, &urlErr)
+	}
+	if slices.Contains(p.RetryStatuses, status) {
+		return true
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return slices.Contains(p.RetryCodes, apiErr.Code())
+	}
+	return false
+}
+
+// backoff returns the delay to wait before the given retry attempt (starting
+// from 1).
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 && d > 0 {
+		jitter := min(p.Jitter, 1)
+		d -= time.Duration(rand.Float64() * jitter * float64(d))
+	}
+	return d
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
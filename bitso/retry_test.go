@@ -0,0 +1,195 @@
+package bitso
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRetryPolicy() *RetryPolicy {
+	p := DefaultRetryPolicy()
+	p.MinBackoff = time.Millisecond
+	p.MaxBackoff = 5 * time.Millisecond
+	return p
+}
+
+func TestRetryPolicy_Disabled(t *testing.T) {
+	var calls int32
+	server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	defer server.Close()
+
+	assert.Nil(t, client.RetryPolicy())
+
+	_, err := client.AvailableBooks()
+
+	require.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestRetryPolicy_RetryableStatus(t *testing.T) {
+	var calls int32
+	server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write(successResponse([]interface{}{}))
+	})
+	defer server.Close()
+
+	client.SetRetryPolicy(testRetryPolicy())
+	books, err := client.AvailableBooks()
+
+	require.NoError(t, err)
+	assert.NotNil(t, books)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestRetryPolicy_MaxAttempts(t *testing.T) {
+	var calls int32
+	server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	defer server.Close()
+
+	policy := testRetryPolicy()
+	policy.MaxAttempts = 4
+	client.SetRetryPolicy(policy)
+
+	_, err := client.AvailableBooks()
+
+	require.Error(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
+
+func TestRetryPolicy_RetryableCode(t *testing.T) {
+	var mu sync.Mutex
+	var nonces []string
+
+	server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bitso "), ":")
+		require.Len(t, parts, 3)
+
+		mu.Lock()
+		nonces = append(nonces, parts[1])
+		n := len(nonces)
+		mu.Unlock()
+
+		if n == 1 {
+			w.Write(errorResponse(201, "Invalid nonce"))
+			return
+		}
+		w.Write(successResponse(map[string]interface{}{"balances": []interface{}{}}))
+	})
+	defer server.Close()
+
+	client.SetAuth("test-key", "test-secret")
+	client.SetRetryPolicy(testRetryPolicy())
+
+	_, err := client.Balances(nil)
+
+	require.NoError(t, err)
+	require.Len(t, nonces, 2)
+	assert.NotEqual(t, nonces[0], nonces[1], "each attempt must be signed with a fresh nonce")
+}
+
+func TestRetryPolicy_NonRetryableCode(t *testing.T) {
+	var calls int32
+	server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write(errorResponse(303, "The field book is missing"))
+	})
+	defer server.Close()
+
+	client.SetRetryPolicy(testRetryPolicy())
+	_, err := client.Trades(nil)
+
+	require.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestRetryPolicy_PlaceOrder(t *testing.T) {
+	order := func(originID string) *OrderPlacement {
+		return &OrderPlacement{
+			Book:     *NewBook(BTC, MXN),
+			Side:     OrderSideBuy,
+			Type:     OrderTypeLimit,
+			Major:    ToMonetary(0.1),
+			Price:    ToMonetary(500000),
+			OriginID: originID,
+		}
+	}
+
+	t.Run("without origin id", func(t *testing.T) {
+		var calls int32
+		server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+		defer server.Close()
+
+		client.SetAuth("test-key", "test-secret")
+		client.SetRetryPolicy(testRetryPolicy())
+
+		_, err := client.PlaceOrder(order(""))
+
+		require.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "orders without origin_id must not be retried")
+	})
+
+	t.Run("with origin id", func(t *testing.T) {
+		var calls int32
+		server, client := mockServer(t, func(w http.ResponseWriter, r *http.Request) {
+			var body OrderPlacement
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "my-order-1", body.OriginID)
+
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write(successResponse(map[string]interface{}{"oid": "new-order-123"}))
+		})
+		defer server.Close()
+
+		client.SetAuth("test-key", "test-secret")
+		client.SetRetryPolicy(testRetryPolicy())
+
+		oid, err := client.PlaceOrder(order("my-order-1"))
+
+		require.NoError(t, err)
+		assert.Equal(t, "new-order-123", oid)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := &RetryPolicy{
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: time.Second,
+	}
+
+	assert.Equal(t, 100*time.Millisecond, p.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, p.backoff(2))
+	assert.Equal(t, 400*time.Millisecond, p.backoff(3))
+	assert.Equal(t, time.Second, p.backoff(5))
+	assert.Equal(t, time.Second, p.backoff(50))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.backoff(2)
+		assert.GreaterOrEqual(t, d, 100*time.Millisecond)
+		assert.LessOrEqual(t, d, 200*time.Millisecond)
+	}
+}
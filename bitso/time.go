@@ -20,6 +20,11 @@ func (t *Time) Time() time.Time {
 	return time.Time(*t)
 }
 
+// MarshalJSON implements json.Marshaler
+func (t Time) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
 // UnmarshalJSON implements json.Unmarshal
 func (t *Time) UnmarshalJSON(in []byte) error {
 	var s string
@@ -0,0 +1,77 @@
+package bitso
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// TimeInForce tells how long a limit order remains active.
+type TimeInForce uint8
+
+// List of time in force policies.
+const (
+	TimeInForceNone TimeInForce = iota
+
+	TimeInForceGoodTillCancelled
+	TimeInForceFillOrKill
+	TimeInForceImmediateOrCancel
+	TimeInForcePostOnly
+)
+
+var timeInForceNames = map[TimeInForce]string{
+	TimeInForceGoodTillCancelled: "goodtillcancelled",
+	TimeInForceFillOrKill:        "fillorkill",
+	TimeInForceImmediateOrCancel: "immediateorcancel",
+	TimeInForcePostOnly:          "postonly",
+}
+
+// MarshalJSON implements json.Marshaler
+func (t TimeInForce) MarshalJSON() ([]byte, error) {
+	if t == TimeInForceNone {
+		return json.Marshal("")
+	}
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *TimeInForce) UnmarshalJSON(in []byte) error {
+	var z string
+	if err := json.Unmarshal(in, &z); err != nil {
+		return err
+	}
+	return t.fromString(z)
+}
+
+func (t TimeInForce) String() string {
+	if z, ok := timeInForceNames[t]; ok {
+		return z
+	}
+	return fmt.Sprintf("TimeInForce(%d)", t)
+}
+
+func (t TimeInForce) Value() (driver.Value, error) {
+	return t.String(), nil
+}
+
+func (t *TimeInForce) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	return t.fromString(value.(string))
+}
+
+func (t *TimeInForce) fromString(z string) error {
+	if z == "" {
+		// Market orders come without a time in force.
+		*t = TimeInForceNone
+		return nil
+	}
+	for tif, name := range timeInForceNames {
+		if z == name {
+			*t = tif
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported time in force %q", z)
+}
@@ -165,6 +165,18 @@ func TestTime_String(t *testing.T) {
 	assert.Equal(t, "2024-01-15T10:30:00+0000", result)
 }
 
+func TestTime_JSONRoundtrip(t *testing.T) {
+	tm := Time(time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC))
+
+	data, err := json.Marshal(tm)
+	require.NoError(t, err)
+	assert.Equal(t, `"2024-01-15T10:30:00+0000"`, string(data))
+
+	var decoded Time
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.True(t, tm.Time().Equal(decoded.Time()))
+}
+
 func TestTime_Time(t *testing.T) {
 	expected := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
 	tm := Time(expected)
@@ -1,10 +1,21 @@
 package bitso
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
 )
 
 const wssURL = `wss://ws.bitso.com`
@@ -20,59 +31,52 @@ type WebSocketReply struct {
 
 // WebSocketTrade represents a message from the "trades" channel.
 type WebSocketTrade struct {
-	Book    Book
-	Payload []struct {
-		TID               uint64   `json:"i"`
-		Amount            Monetary `json:"a"`
-		Price             Monetary `json:"r"`
-		Value             Monetary `json:"v"`
-		MakerSide         string   `json:"t"`
-		CreationTimestamp uint64   `json:"x"`
-		MakerOrderID      string   `json:"mo"`
-		TakerOrderID      string   `json:"to"`
+	Book    Book          `json:"book"`
+	Payload []StreamTrade `json:"payload"`
+}
+
+// Trades returns the trades of the message as Trade values.
+func (m WebSocketTrade) Trades() []Trade {
+	trades := make([]Trade, 0, len(m.Payload))
+	for _, t := range m.Payload {
+		trades = append(trades, t.Trade(m.Book))
 	}
+	return trades
 }
 
 // WebSocketDiffOrder represents a message from the "diff-orders" channel.
 type WebSocketDiffOrder struct {
-	Book    Book
-	Payload []struct {
-		Timestamp           uint64   `json:"d"`
-		Price               Monetary `json:"r"`
-		Status              string   `json:"s"`
-		Position            int      `json:"t"`
-		Amount              Monetary `json:"a"`
-		Value               Monetary `json:"v"`
-		LastUpdateTimestamp uint64   `json:"z"`
-		OrderID             string   `json:"o"`
-	}
+	Book     Book              `json:"book"`
+	Sequence uint64            `json:"sequence"`
+	Payload  []StreamOrderDiff `json:"payload"`
 }
 
-// WebSocketOrder represents a message from the "diff-orders" channel.
+// WebSocketOrder represents a message from the "orders" channel.
 type WebSocketOrder struct {
-	Book    Book
-	Payload struct {
-		Bids []struct {
-			Amount    Monetary `json:"a"`
-			OrderID   string   `json:"o"`
-			Position  int      `json:"t"`
-			Price     Monetary `json:"r"`
-			Status    string   `json:"s"`
-			Timestamp uint64   `json:"d"`
-			Value     Monetary `json:"v"`
-		} `json:"bids"`
-		Asks []struct {
-			Amount    Monetary `json:"a"`
-			OrderID   string   `json:"o"`
-			Position  int      `json:"t"`
-			Price     Monetary `json:"r"`
-			Status    string   `json:"s"`
-			Timestamp uint64   `json:"d"`
-			Value     Monetary `json:"v"`
-		} `json:"asks"`
+	Book     Book   `json:"book"`
+	Sequence uint64 `json:"sequence"`
+	Payload  struct {
+		Bids []StreamOrder `json:"bids"`
+		Asks []StreamOrder `json:"asks"`
 	} `json:"payload"`
 }
 
+// OrderBook converts the snapshot into an OrderBook.
+func (m WebSocketOrder) OrderBook() *OrderBook {
+	orderBook := &OrderBook{
+		Bids:     make([]Order, 0, len(m.Payload.Bids)),
+		Asks:     make([]Order, 0, len(m.Payload.Asks)),
+		Sequence: strconv.FormatUint(m.Sequence, 10),
+	}
+	for _, o := range m.Payload.Bids {
+		orderBook.Bids = append(orderBook.Bids, o.Order(m.Book))
+	}
+	for _, o := range m.Payload.Asks {
+		orderBook.Asks = append(orderBook.Asks, o.Order(m.Book))
+	}
+	return orderBook
+}
+
 // WebSocketMessage represents a message that can be sent to channel.
 type WebSocketMessage struct {
 	Action string `json:"action"`
@@ -80,106 +84,634 @@ type WebSocketMessage struct {
 	Type   string `json:"type"`
 }
 
+func (m WebSocketMessage) sameChannel(other WebSocketMessage) bool {
+	if m.Type != other.Type || (m.Book == nil) != (other.Book == nil) {
+		return false
+	}
+	return m.Book == nil || *m.Book == *other.Book
+}
+
+// WebSocketEventType tells what happened to a websocket connection.
+type WebSocketEventType uint8
+
+// List of websocket event types.
+const (
+	WebSocketEventNone WebSocketEventType = iota
+
+	WebSocketEventConnected
+	WebSocketEventReconnecting
+	WebSocketEventClosed
+	WebSocketEventSequenceGap
+)
+
+var webSocketEventNames = map[WebSocketEventType]string{
+	WebSocketEventConnected:    "connected",
+	WebSocketEventReconnecting: "reconnecting",
+	WebSocketEventClosed:       "closed",
+	WebSocketEventSequenceGap:  "sequence-gap",
+}
+
+func (e WebSocketEventType) String() string {
+	if z, ok := webSocketEventNames[e]; ok {
+		return z
+	}
+	return fmt.Sprintf("WebSocketEventType(%d)", e)
+}
+
+// WebSocketEvent reports a change in the state of a websocket connection.
+type WebSocketEvent struct {
+	Type WebSocketEventType
+	Time time.Time
+
+	// Error that caused the event, if any.
+	Err error
+
+	// Channel, Book, Expected and Received describe a WebSocketEventSequenceGap
+	// event: a message of the channel was either missed, when Received is
+	// greater than Expected, or arrived out of order.
+	Channel  string
+	Book     Book
+	Expected uint64
+	Received uint64
+}
+
+// defaultReconnectPolicy tells how a WebSocketConn reconnects after losing
+// its connection.
+func defaultReconnectPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 10,
+		MinBackoff:  500 * time.Millisecond,
+		MaxBackoff:  30 * time.Second,
+		Jitter:      0.5,
+	}
+}
+
+// defaultInboxBuffer is the capacity of the channel returned by Receive.
+const defaultInboxBuffer = 8
+
+// defaultIdleTimeout is how long a WebSocketConn waits for a message before
+// considering the connection dead.
+const defaultIdleTimeout = time.Minute
+
+var (
+	// ErrWebSocketClosed is returned when using a connection that was closed.
+	ErrWebSocketClosed = errors.New("websocket connection closed")
+
+	// ErrWebSocketIdle is reported when no message was received within the
+	// idle timeout.
+	ErrWebSocketIdle = errors.New("websocket connection idle")
+)
+
 // A WebSocketConn establishes a connection with Bitso's websocket service to
 // send and receive messages over the ws protocol.
+//
+// If the connection is lost a WebSocketConn reconnects automatically and
+// subscribes again to all of its channels. The channel returned by Receive,
+// and those returned by the typed subscription methods, are closed once the
+// connection is closed for good.
 type WebSocketConn struct {
-	endpoint string
-	conn     *websocket.Conn
+	endpoint  string
+	dialer    *websocket.Dialer
+	header    http.Header
+	reconnect *RetryPolicy
+	logger    zerolog.Logger
+
+	idleTimeout time.Duration
+	lastMessage atomic.Int64
+
+	frameHandler func(receivedAt time.Time, frame []byte)
+
+	// sequences holds the last sequence number seen on each channel and
+	// book, it's only used by the reader.
+	sequences map[sequenceKey]uint64
+
+	conn *websocket.Conn
 
-	inbox chan interface{}
+	inbox  chan interface{}
+	events chan WebSocketEvent
+	errors chan error
+
+	bufferSize int
+	overflow   OverflowPolicy
+	counters   overflowCounters
+
+	// subscriptions holds every channel subscribed on the server, to replay
+	// them after reconnecting.
+	subscriptions []WebSocketMessage
+	// inboxChannels are the channels requested with Subscribe.
+	inboxChannels []WebSocketMessage
+	streams       []*stream
+
+	done   chan struct{}
+	cancel context.CancelFunc
+	closed bool
+
+	mu      sync.Mutex
+	writeMu sync.Mutex
 }
 
-// Receive returns a channel where received messages are sent.
+// Receive returns a channel where the messages of the channels requested with
+// Subscribe are sent.
 func (ws *WebSocketConn) Receive() chan interface{} {
 	return ws.inbox
 }
 
-// WebSocketConn creates a websocket handler and establishes a connection with
-// Bitso's websocket servers.
-func NewWebSocketConn() (*WebSocketConn, error) {
+// Events returns a channel that reports changes in the state of the
+// connection. Events are dropped if the channel is not drained.
+func (ws *WebSocketConn) Events() <-chan WebSocketEvent {
+	return ws.events
+}
+
+// Errors returns a channel that reports errors that did not close the
+// connection, such as messages that could not be decoded. Errors are dropped
+// if the channel is not drained.
+func (ws *WebSocketConn) Errors() <-chan error {
+	return ws.errors
+}
+
+// Stats returns the delivery counters of the connection.
+func (ws *WebSocketConn) Stats() WebSocketStats {
+	return ws.counters.stats()
+}
+
+// LastMessageAt returns the time the last message was received, keep alive
+// messages included. It's the zero time if nothing was received yet.
+func (ws *WebSocketConn) LastMessageAt() time.Time {
+	ns := ws.lastMessage.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+func (ws *WebSocketConn) touch() time.Time {
+	now := time.Now()
+	ws.lastMessage.Store(now.UnixNano())
+	return now
+}
+
+// SetLogLevel sets the log level for the connection.
+func (ws *WebSocketConn) SetLogLevel(level zerolog.Level) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.logger = ws.logger.Level(level)
+}
+
+func (ws *WebSocketConn) log() *zerolog.Logger {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	logger := ws.logger
+	return &logger
+}
+
+// NewWebSocketConn creates a websocket handler and establishes a connection
+// with Bitso's websocket servers.
+func NewWebSocketConn(opts ...WebSocketOption) (*WebSocketConn, error) {
+	return NewWebSocketConnContext(context.Background(), opts...)
+}
+
+// NewWebSocketConnContext is like NewWebSocketConn but carries a context. The
+// context only bounds the initial connection attempt, not the lifetime of the
+// connection.
+func NewWebSocketConnContext(ctx context.Context, opts ...WebSocketOption) (*WebSocketConn, error) {
 	ws := &WebSocketConn{
-		endpoint: wssURL,
-		inbox:    make(chan interface{}, 8),
+		endpoint:    wssURL,
+		dialer:      websocket.DefaultDialer,
+		reconnect:   defaultReconnectPolicy(),
+		idleTimeout: defaultIdleTimeout,
+		logger:      zerolog.New(os.Stderr).With().Timestamp().Logger().Level(LogLevelInfo),
+		events:      make(chan WebSocketEvent, 16),
+		errors:      make(chan error, 16),
+		done:        make(chan struct{}),
 	}
+	for _, opt := range opts {
+		opt(ws)
+	}
+	inboxBuffer := defaultInboxBuffer
+	if ws.bufferSize > 0 {
+		inboxBuffer = ws.bufferSize
+	}
+	ws.inbox = make(chan interface{}, inboxBuffer)
 
-	var err error
-	ws.conn, _, err = websocket.DefaultDialer.Dial(wssURL, nil)
+	conn, err := ws.dial(ctx)
 	if err != nil {
 		return nil, err
 	}
+	ws.conn = conn
+	ws.emit(WebSocketEventConnected, nil)
 
-	go func() {
-		defer ws.Close()
-		for {
-			_, data, err := ws.conn.ReadMessage()
-			if err != nil {
-				log.Printf("failed to read message: %v", err)
-				return
+	// Reconnection attempts are cancelled when the connection is closed.
+	var lifetime context.Context
+	lifetime, ws.cancel = context.WithCancel(context.Background())
+
+	go ws.run(lifetime, conn)
+
+	return ws, nil
+}
+
+// URL returns the URL of the websocket service.
+func (ws *WebSocketConn) URL() string {
+	return ws.endpoint
+}
+
+func (ws *WebSocketConn) dial(ctx context.Context) (*websocket.Conn, error) {
+	dialer := ws.dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	conn, res, err := dialer.DialContext(ctx, ws.endpoint, ws.header)
+	if err != nil {
+		if res != nil {
+			return nil, fmt.Errorf("websocket handshake failed with status %d: %w", res.StatusCode, err)
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (ws *WebSocketConn) emit(eventType WebSocketEventType, err error) {
+	ws.emitEvent(WebSocketEvent{
+		Type: eventType,
+		Time: time.Now(),
+		Err:  err,
+	})
+}
+
+func (ws *WebSocketConn) emitEvent(event WebSocketEvent) {
+	select {
+	case ws.events <- event:
+	default:
+	}
+}
+
+func (ws *WebSocketConn) isClosed() bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	return ws.closed
+}
+
+// run reads messages until the connection is closed for good, reconnecting
+// whenever the connection is lost.
+func (ws *WebSocketConn) run(ctx context.Context, conn *websocket.Conn) {
+	defer ws.closeStreams()
+	defer close(ws.inbox)
+
+	for {
+		err := ws.read(conn)
+		conn.Close()
+
+		if ws.isClosed() {
+			ws.emit(WebSocketEventClosed, nil)
+			return
+		}
+
+		ws.log().Warn().Err(err).Msg("lost websocket connection, reconnecting")
+		ws.emit(WebSocketEventReconnecting, err)
+
+		conn, err = ws.redial(ctx)
+		if err != nil {
+			ws.log().Error().Err(err).Msg("failed to reconnect")
+			if conn := ws.shutdown(); conn != nil {
+				conn.Close()
 			}
+			ws.emit(WebSocketEventClosed, err)
+			return
+		}
+		ws.emit(WebSocketEventConnected, nil)
+	}
+}
 
-			var reply WebSocketReply
-			if err := json.Unmarshal(data, &reply); err != nil {
-				log.Printf("failed to unmarshal message: %v", err)
-				return
+// redial tries to establish a new connection and replays all subscriptions
+// on it.
+func (ws *WebSocketConn) redial(ctx context.Context) (*websocket.Conn, error) {
+	if ws.reconnect == nil {
+		return nil, errors.New("reconnection is disabled")
+	}
+
+	var err error
+	for attempt := 1; attempt <= ws.reconnect.maxAttempts(); attempt++ {
+		if sleepContext(ctx, ws.reconnect.backoff(attempt)) != nil {
+			return nil, ErrWebSocketClosed
+		}
+
+		var conn *websocket.Conn
+		conn, err = ws.dial(ctx)
+		if err != nil {
+			continue
+		}
+
+		if err = ws.resubscribe(conn); err != nil {
+			conn.Close()
+			if errors.Is(err, ErrWebSocketClosed) {
+				return nil, err
 			}
+			continue
+		}
+		return conn, nil
+	}
+	return nil, fmt.Errorf("giving up after %d attempts: %w", ws.reconnect.maxAttempts(), err)
+}
+
+func (ws *WebSocketConn) resubscribe(conn *websocket.Conn) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.closed {
+		return ErrWebSocketClosed
+	}
+
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+
+	for _, m := range ws.subscriptions {
+		if err := conn.WriteJSON(m); err != nil {
+			return err
+		}
+	}
+	ws.conn = conn
+	return nil
+}
+
+// read decodes messages from conn until it fails. Messages that can not be
+// decoded are reported and skipped.
+//
+// If the idle timeout is enabled, read fails with ErrWebSocketIdle when no
+// message, keep alive messages included, arrives within the timeout while
+// waiting for the next one. Pongs don't count: a server that answers pings
+// but stopped sending data is dead to us. conn is pinged in the meantime to
+// keep proxies from closing it. The time spent dispatching messages to slow
+// consumers does not count either.
+func (ws *WebSocketConn) read(conn *websocket.Conn) error {
+	if ws.idleTimeout > 0 {
+		stop := make(chan struct{})
+		defer close(stop)
+
+		go ws.ping(conn, stop)
+	}
 
-			switch reply.Type {
-			case "diff-orders":
-				if reply.Payload != nil {
-					var diff WebSocketDiffOrder
-					if err := json.Unmarshal(data, &diff); err != nil {
-						log.Printf("failed to unmarshal diff order: %v", err)
-						return
-					}
-					ws.inbox <- diff
-					continue
-				}
-			case "ka":
-				// keep alive
-				continue
-			case "orders":
-				if reply.Payload != nil {
-					var order WebSocketOrder
-					if err := json.Unmarshal(data, &order); err != nil {
-						log.Printf("failed to unmarshal order: %v", err)
-						return
-					}
-					ws.inbox <- order
-					continue
-				}
-			case "trades":
-				if reply.Payload != nil {
-					var trade WebSocketTrade
-					if err := json.Unmarshal(data, &trade); err != nil {
-						log.Printf("failed to unmarshal trade: %v", err)
-						return
-					}
-					ws.inbox <- trade
-					continue
-				}
+	for {
+		if ws.idleTimeout > 0 {
+			if err := conn.SetReadDeadline(time.Now().Add(ws.idleTimeout)); err != nil {
+				return err
 			}
+		}
 
-			ws.inbox <- reply
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return fmt.Errorf("%w: nothing received in %v", ErrWebSocketIdle, ws.idleTimeout)
+			}
+			return err
 		}
-	}()
+		now := ws.touch()
 
-	return ws, nil
+		if ws.frameHandler != nil {
+			ws.frameHandler(now, data)
+		}
+
+		msg, err := DecodeWebSocketMessage(data)
+		if err != nil {
+			ws.reportError(err)
+			continue
+		}
+		if reply, ok := msg.(WebSocketReply); ok && reply.Type == "ka" {
+			// keep alive
+			continue
+		}
+		ws.checkSequence(msg)
+
+		if err := ws.dispatch(msg); err != nil {
+			return err
+		}
+	}
+}
+
+type sequenceKey struct {
+	channel string
+	book    Book
+}
+
+// checkSequence reports a WebSocketEventSequenceGap event if msg does not
+// follow the previous message of its channel and book. Diff orders must be
+// consecutive, order book snapshots must only move forward. Messages are
+// delivered anyway.
+func (ws *WebSocketConn) checkSequence(msg interface{}) {
+	var (
+		key      sequenceKey
+		sequence uint64
+	)
+	switch m := msg.(type) {
+	case WebSocketDiffOrder:
+		key, sequence = sequenceKey{"diff-orders", m.Book}, m.Sequence
+	case WebSocketOrder:
+		key, sequence = sequenceKey{"orders", m.Book}, m.Sequence
+	default:
+		return
+	}
+	if sequence == 0 {
+		return
+	}
+
+	if ws.sequences == nil {
+		ws.sequences = map[sequenceKey]uint64{}
+	}
+	last, seen := ws.sequences[key]
+	if !seen || sequence > last {
+		ws.sequences[key] = sequence
+	}
+	if !seen {
+		return
+	}
+
+	expected := last + 1
+	if sequence == expected || (key.channel == "orders" && sequence > last) {
+		return
+	}
+
+	ws.log().Warn().
+		Str("type", key.channel).
+		Str("book", key.book.String()).
+		Uint64("expected", expected).
+		Uint64("received", sequence).
+		Msg("unexpected sequence number")
+
+	ws.emitEvent(WebSocketEvent{
+		Type:     WebSocketEventSequenceGap,
+		Time:     time.Now(),
+		Channel:  key.channel,
+		Book:     key.book,
+		Expected: expected,
+		Received: sequence,
+	})
+}
+
+// ping sends ping frames to conn until stop is closed.
+func (ws *WebSocketConn) ping(conn *websocket.Conn, stop <-chan struct{}) {
+	ticker := time.NewTicker(ws.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			deadline := time.Now().Add(ws.idleTimeout / 2)
+			if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				// The reader will notice.
+				return
+			}
+		}
+	}
+}
+
+// DecodeWebSocketMessage decodes a frame received from Bitso's websocket
+// service. The result is a WebSocketTrade, WebSocketDiffOrder or
+// WebSocketOrder for channel messages, and a WebSocketReply for anything
+// else, including keep alive messages. Decoding failures are reported as a
+// *WebSocketDecodeError.
+func DecodeWebSocketMessage(data []byte) (interface{}, error) {
+	var reply WebSocketReply
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return nil, newWebSocketDecodeError(data, fmt.Errorf("failed to unmarshal message: %w", err))
+	}
+	if reply.Payload == nil {
+		return reply, nil
+	}
+
+	var (
+		msg interface{}
+		err error
+	)
+	switch reply.Type {
+	case "diff-orders":
+		var diff WebSocketDiffOrder
+		if err = json.Unmarshal(data, &diff); err != nil {
+			err = fmt.Errorf("failed to unmarshal diff order: %w", err)
+		}
+		msg = diff
+	case "orders":
+		var order WebSocketOrder
+		if err = json.Unmarshal(data, &order); err != nil {
+			err = fmt.Errorf("failed to unmarshal order: %w", err)
+		}
+		msg = order
+	case "trades":
+		var trade WebSocketTrade
+		if err = json.Unmarshal(data, &trade); err != nil {
+			err = fmt.Errorf("failed to unmarshal trade: %w", err)
+		}
+		msg = trade
+	default:
+		return reply, nil
+	}
+	if err != nil {
+		return nil, newWebSocketDecodeError(data, err)
+	}
+	return msg, nil
+}
+
+// A WebSocketDecodeError is reported when a frame can not be decoded.
+type WebSocketDecodeError struct {
+	// Frame is the raw message.
+	Frame []byte
+	Err   error
+}
+
+func newWebSocketDecodeError(data []byte, err error) *WebSocketDecodeError {
+	return &WebSocketDecodeError{Frame: slices.Clone(data), Err: err}
+}
+
+func (e *WebSocketDecodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *WebSocketDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// reportError logs err and sends it to the channel returned by Errors, unless
+// the channel is full.
+func (ws *WebSocketConn) reportError(err error) {
+	ws.log().Warn().Err(err).Msg("websocket error")
+
+	select {
+	case ws.errors <- err:
+	default:
+	}
+}
+
+// shutdown marks the connection as closed and returns the underlying
+// connection, if any.
+func (ws *WebSocketConn) shutdown() *websocket.Conn {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.closed {
+		return nil
+	}
+	ws.closed = true
+	if ws.done != nil {
+		close(ws.done)
+	}
+	if ws.cancel != nil {
+		ws.cancel()
+	}
+	return ws.conn
 }
 
 // Close closes the active connection with Bitso's websocket servers.
 func (ws *WebSocketConn) Close() error {
-	if ws.conn != nil {
-		return ws.conn.Close()
+	if conn := ws.shutdown(); conn != nil {
+		return conn.Close()
 	}
 	return nil
 }
 
-// Subscribe subscribes to a messages channel.
+// Subscribe subscribes to a messages channel, messages are delivered to the
+// channel returned by Receive. Subscriptions are restored automatically after
+// reconnecting.
 func (ws *WebSocketConn) Subscribe(book *Book, channelName string) error {
+	return ws.subscribe(book, channelName, nil)
+}
+
+// subscribe sends a subscription request and delivers its messages to s, or
+// to the inbox if s is nil.
+func (ws *WebSocketConn) subscribe(book *Book, channelName string, s *stream) error {
 	m := WebSocketMessage{
 		Action: "subscribe",
 		Book:   book,
 		Type:   channelName,
 	}
-	return ws.conn.WriteJSON(m)
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.closed {
+		return ErrWebSocketClosed
+	}
+	if !slices.ContainsFunc(ws.subscriptions, m.sameChannel) {
+		ws.subscriptions = append(ws.subscriptions, m)
+	}
+	if s != nil {
+		ws.streams = append(ws.streams, s)
+	} else if !slices.ContainsFunc(ws.inboxChannels, m.sameChannel) {
+		ws.inboxChannels = append(ws.inboxChannels, m)
+	}
+
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+
+	if err := ws.conn.WriteJSON(m); err != nil {
+		// The reader will notice the broken connection and the subscription
+		// will be sent again after reconnecting.
+		ws.logger.Warn().
+			Str("type", channelName).
+			Err(err).
+			Msg("failed to subscribe, will retry after reconnecting")
+	}
+	return nil
 }
@@ -0,0 +1,95 @@
+package bitso
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+)
+
+// A WebSocketOption configures a WebSocketConn before it connects.
+type WebSocketOption func(*WebSocketConn)
+
+// WithWebSocketURL sets the URL of the websocket service, the default is
+// Bitso's production endpoint.
+func WithWebSocketURL(endpoint string) WebSocketOption {
+	return func(ws *WebSocketConn) {
+		ws.endpoint = endpoint
+	}
+}
+
+// WithWebSocketDialer sets the dialer used to connect and reconnect, use it to
+// configure TLS, proxies or handshake timeouts. The default is
+// websocket.DefaultDialer.
+func WithWebSocketDialer(dialer *websocket.Dialer) WebSocketOption {
+	return func(ws *WebSocketConn) {
+		ws.dialer = dialer
+	}
+}
+
+// WithWebSocketHeader sets additional headers sent on the opening handshake.
+func WithWebSocketHeader(header http.Header) WebSocketOption {
+	return func(ws *WebSocketConn) {
+		ws.header = header.Clone()
+	}
+}
+
+// WithReconnectPolicy sets how the connection is restored after it's lost.
+// A nil policy disables reconnection.
+func WithReconnectPolicy(policy *RetryPolicy) WebSocketOption {
+	return func(ws *WebSocketConn) {
+		ws.reconnect = policy
+	}
+}
+
+// WithWebSocketLogger sets the logger used by the connection.
+func WithWebSocketLogger(logger zerolog.Logger) WebSocketOption {
+	return func(ws *WebSocketConn) {
+		ws.logger = logger
+	}
+}
+
+// WithWebSocketLogLevel sets the log level for the connection.
+func WithWebSocketLogLevel(level zerolog.Level) WebSocketOption {
+	return func(ws *WebSocketConn) {
+		ws.logger = ws.logger.Level(level)
+	}
+}
+
+// WithIdleTimeout sets how long to wait for a message, keep alive messages
+// included, before considering the connection dead and reconnecting. Pongs
+// don't reset the timeout. The default is one minute, a non positive value
+// disables the timeout.
+func WithIdleTimeout(timeout time.Duration) WebSocketOption {
+	return func(ws *WebSocketConn) {
+		ws.idleTimeout = timeout
+	}
+}
+
+// WithBufferSize sets the capacity of the channel returned by Receive and of
+// those returned by the typed subscription methods. By default Receive buffers
+// 8 messages and typed subscriptions 64.
+func WithBufferSize(size int) WebSocketOption {
+	return func(ws *WebSocketConn) {
+		ws.bufferSize = size
+	}
+}
+
+// WithOverflowPolicy sets what to do with incoming messages when a consumer
+// channel is full. The default is OverflowBlock.
+func WithOverflowPolicy(policy OverflowPolicy) WebSocketOption {
+	return func(ws *WebSocketConn) {
+		ws.overflow = policy
+	}
+}
+
+// WithFrameHandler sets a function that is called with every data frame
+// received, before it is decoded, and the time it was received. It runs on
+// the goroutine that reads the connection so it should return quickly, and
+// it must not modify frame.
+func WithFrameHandler(fn func(receivedAt time.Time, frame []byte)) WebSocketOption {
+	return func(ws *WebSocketConn) {
+		ws.frameHandler = fn
+	}
+}
@@ -0,0 +1,152 @@
+package bitso
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// OverflowPolicy tells what a WebSocketConn does with a message when the
+// consumer channel is full.
+type OverflowPolicy uint8
+
+// List of overflow policies.
+const (
+	// OverflowBlock waits until the consumer makes room, stalling the
+	// connection in the meantime.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest buffered message.
+	OverflowDropOldest
+	// OverflowDropNewest discards the incoming message.
+	OverflowDropNewest
+	// OverflowCoalesce replaces buffered order book snapshots with a newer
+	// snapshot of the same book. If there is nothing to replace the oldest
+	// message is discarded.
+	OverflowCoalesce
+)
+
+var overflowPolicyNames = map[OverflowPolicy]string{
+	OverflowBlock:      "block",
+	OverflowDropOldest: "drop-oldest",
+	OverflowDropNewest: "drop-newest",
+	OverflowCoalesce:   "coalesce",
+}
+
+func (p OverflowPolicy) String() string {
+	if z, ok := overflowPolicyNames[p]; ok {
+		return z
+	}
+	return fmt.Sprintf("OverflowPolicy(%d)", p)
+}
+
+// WebSocketStats holds the delivery counters of a WebSocketConn.
+type WebSocketStats struct {
+	// Delivered is the number of messages handed to consumers.
+	Delivered uint64
+	// Dropped is the number of messages discarded because a consumer was too
+	// slow.
+	Dropped uint64
+	// Coalesced is the number of order book snapshots replaced by a newer
+	// one.
+	Coalesced uint64
+}
+
+type overflowCounters struct {
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+	coalesced atomic.Uint64
+}
+
+func (c *overflowCounters) stats() WebSocketStats {
+	return WebSocketStats{
+		Delivered: c.delivered.Load(),
+		Dropped:   c.dropped.Load(),
+		Coalesced: c.coalesced.Load(),
+	}
+}
+
+// offer sends v to ch following policy. It returns false if stop or done were
+// closed while waiting for room. ch must not have any other sender.
+func offer[T any](ch chan T, v T, policy OverflowPolicy, counters *overflowCounters, stop, done <-chan struct{}) bool {
+	if policy == OverflowBlock {
+		select {
+		case ch <- v:
+			counters.delivered.Add(1)
+			return true
+		case <-stop:
+		case <-done:
+		}
+		return false
+	}
+
+	select {
+	case ch <- v:
+		counters.delivered.Add(1)
+		return true
+	default:
+	}
+
+	switch policy {
+	case OverflowDropNewest:
+		counters.dropped.Add(1)
+		return true
+	case OverflowCoalesce:
+		if n := coalesce(ch, v); n > 0 {
+			counters.coalesced.Add(uint64(n))
+			counters.delivered.Add(1)
+			return true
+		}
+	}
+
+	// Make room by discarding the oldest message, the consumer may have
+	// taken it already.
+	select {
+	case <-ch:
+		counters.dropped.Add(1)
+	default:
+	}
+	select {
+	case ch <- v:
+		counters.delivered.Add(1)
+	default:
+		counters.dropped.Add(1)
+	}
+	return true
+}
+
+// coalesce removes the buffered snapshots of the same book as v from ch and
+// enqueues v. It returns the number of messages removed, if v is not a
+// snapshot or nothing could be removed ch is left as it was.
+func coalesce[T any](ch chan T, v T) int {
+	snapshot, ok := any(v).(WebSocketOrder)
+	if !ok {
+		return 0
+	}
+
+	var buffered []T
+drain:
+	for len(buffered) < cap(ch) {
+		select {
+		case m := <-ch:
+			buffered = append(buffered, m)
+		default:
+			break drain
+		}
+	}
+
+	kept := buffered[:0:0]
+	for _, m := range buffered {
+		if o, ok := any(m).(WebSocketOrder); ok && o.Book == snapshot.Book {
+			continue
+		}
+		kept = append(kept, m)
+	}
+
+	removed := len(buffered) - len(kept)
+	if removed > 0 {
+		kept = append(kept, v)
+	}
+	for _, m := range kept {
+		ch <- m
+	}
+	return removed
+}
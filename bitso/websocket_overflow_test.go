@@ -0,0 +1,148 @@
+package bitso
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func drain[T any](ch chan T) []T {
+	var res []T
+	for {
+		select {
+		case v := <-ch:
+			res = append(res, v)
+		default:
+			return res
+		}
+	}
+}
+
+func TestOffer_Block(t *testing.T) {
+	var counters overflowCounters
+
+	ch := make(chan int, 1)
+	assert.True(t, offer(ch, 1, OverflowBlock, &counters, nil, nil))
+
+	stop := make(chan struct{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(stop)
+	}()
+	assert.False(t, offer(ch, 2, OverflowBlock, &counters, stop, nil))
+
+	assert.Equal(t, []int{1}, drain(ch))
+	assert.Equal(t, WebSocketStats{Delivered: 1}, counters.stats())
+}
+
+func TestOffer_DropNewest(t *testing.T) {
+	var counters overflowCounters
+
+	ch := make(chan int, 2)
+	for i := 1; i <= 4; i++ {
+		assert.True(t, offer(ch, i, OverflowDropNewest, &counters, nil, nil))
+	}
+
+	assert.Equal(t, []int{1, 2}, drain(ch))
+	assert.Equal(t, WebSocketStats{Delivered: 2, Dropped: 2}, counters.stats())
+}
+
+func TestOffer_DropOldest(t *testing.T) {
+	var counters overflowCounters
+
+	ch := make(chan int, 2)
+	for i := 1; i <= 4; i++ {
+		assert.True(t, offer(ch, i, OverflowDropOldest, &counters, nil, nil))
+	}
+
+	assert.Equal(t, []int{3, 4}, drain(ch))
+	assert.Equal(t, WebSocketStats{Delivered: 4, Dropped: 2}, counters.stats())
+}
+
+func TestOffer_Coalesce(t *testing.T) {
+	var counters overflowCounters
+
+	btc, eth := *NewBook(BTC, MXN), *NewBook(ETH, MXN)
+
+	ch := make(chan interface{}, 3)
+	for _, msg := range []interface{}{
+		WebSocketOrder{Book: btc, Sequence: 1},
+		WebSocketTrade{Book: btc},
+		WebSocketOrder{Book: eth, Sequence: 1},
+		WebSocketOrder{Book: btc, Sequence: 2},
+	} {
+		assert.True(t, offer(ch, msg, OverflowCoalesce, &counters, nil, nil))
+	}
+
+	assert.Equal(t, []interface{}{
+		WebSocketTrade{Book: btc},
+		WebSocketOrder{Book: eth, Sequence: 1},
+		WebSocketOrder{Book: btc, Sequence: 2},
+	}, drain(ch))
+	assert.Equal(t, WebSocketStats{Delivered: 4, Coalesced: 1}, counters.stats())
+
+	// Nothing to coalesce, the oldest message is dropped.
+	for _, msg := range []interface{}{
+		WebSocketTrade{Book: btc},
+		WebSocketTrade{Book: eth},
+		WebSocketDiffOrder{Book: btc},
+		WebSocketOrder{Book: btc, Sequence: 3},
+	} {
+		assert.True(t, offer(ch, msg, OverflowCoalesce, &counters, nil, nil))
+	}
+
+	assert.Equal(t, []interface{}{
+		WebSocketTrade{Book: eth},
+		WebSocketDiffOrder{Book: btc},
+		WebSocketOrder{Book: btc, Sequence: 3},
+	}, drain(ch))
+	assert.Equal(t, WebSocketStats{Delivered: 8, Dropped: 1, Coalesced: 1}, counters.stats())
+}
+
+func TestOverflowPolicy_String(t *testing.T) {
+	assert.Equal(t, "block", OverflowBlock.String())
+	assert.Equal(t, "drop-oldest", OverflowDropOldest.String())
+	assert.Equal(t, "drop-newest", OverflowDropNewest.String())
+	assert.Equal(t, "coalesce", OverflowCoalesce.String())
+	assert.Equal(t, "OverflowPolicy(9)", OverflowPolicy(9).String())
+}
+
+func TestWebSocketConn_Overflow(t *testing.T) {
+	server := newTestWebSocketServer(t)
+
+	ws, err := NewWebSocketConn(
+		WithWebSocketURL(server.endpoint()),
+		WithReconnectPolicy(testReconnectPolicy(3)),
+		WithWebSocketLogger(zerolog.Nop()),
+		WithBufferSize(2),
+		WithOverflowPolicy(OverflowDropOldest),
+	)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	conn := server.accept(t)
+
+	trades, err := ws.SubscribeTrades(NewBook(BTC, MXN))
+	require.NoError(t, err)
+	server.expectMessage(t)
+
+	// Nobody reads the trades channel, the reader must not stall.
+	for i := 1; i <= 5; i++ {
+		frame := fmt.Sprintf(`{"type": "trades", "book": "btc_mxn", "payload": [{"i": %d, "a": "1", "r": "1", "v": "1", "t": "0"}]}`, i)
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(frame)))
+	}
+
+	require.Eventually(t, func() bool {
+		return ws.Stats().Dropped == 3
+	}, time.Second, time.Millisecond)
+
+	first, second := <-trades, <-trades
+	assert.Equal(t, TID(4), first.Payload[0].TID)
+	assert.Equal(t, TID(5), second.Payload[0].TID)
+	assert.Equal(t, uint64(5), ws.Stats().Delivered)
+}
@@ -0,0 +1,275 @@
+package bitso
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// streamInt decodes integers that Bitso sends either as numbers or as
+// strings.
+type streamInt int64
+
+func (n *streamInt) UnmarshalJSON(in []byte) error {
+	in = bytes.Trim(in, `"`)
+	if len(in) == 0 || string(in) == "null" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(string(in), 10, 64)
+	if err != nil {
+		return err
+	}
+	*n = streamInt(v)
+	return nil
+}
+
+// streamTime converts a timestamp in milliseconds into a time.Time.
+func streamTime(ms streamInt) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(ms))
+}
+
+func streamMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+// streamSide converts the side of a stream entry: 0 means buy and 1 means
+// sell.
+func streamSide(n streamInt) OrderSide {
+	switch n {
+	case 0:
+		return OrderSideBuy
+	case 1:
+		return OrderSideSell
+	}
+	return OrderSideNone
+}
+
+func streamSideCode(side OrderSide) int {
+	if side == OrderSideSell {
+		return 1
+	}
+	return 0
+}
+
+// streamStatus converts the status of a stream entry, unknown statuses
+// become OrderStatusNone.
+func streamStatus(z string) OrderStatus {
+	var status OrderStatus
+	if err := status.fromString(z); err != nil {
+		return OrderStatusNone
+	}
+	return status
+}
+
+func streamStatusName(status OrderStatus) string {
+	if status == OrderStatusNone {
+		return ""
+	}
+	return status.String()
+}
+
+// StreamTrade represents a single trade from the "trades" channel.
+type StreamTrade struct {
+	TID          TID
+	Amount       Monetary
+	Price        Monetary
+	Value        Monetary
+	MakerSide    OrderSide
+	CreatedAt    time.Time
+	MakerOrderID string
+	TakerOrderID string
+}
+
+type rawStreamTrade struct {
+	TID          TID       `json:"i"`
+	Amount       Monetary  `json:"a"`
+	Price        Monetary  `json:"r"`
+	Value        Monetary  `json:"v"`
+	MakerSide    streamInt `json:"t"`
+	CreatedAt    streamInt `json:"x"`
+	MakerOrderID string    `json:"mo,omitempty"`
+	TakerOrderID string    `json:"to,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (t StreamTrade) MarshalJSON() ([]byte, error) {
+	return json.Marshal(rawStreamTrade{
+		TID:          t.TID,
+		Amount:       t.Amount,
+		Price:        t.Price,
+		Value:        t.Value,
+		MakerSide:    streamInt(streamSideCode(t.MakerSide)),
+		CreatedAt:    streamInt(streamMillis(t.CreatedAt)),
+		MakerOrderID: t.MakerOrderID,
+		TakerOrderID: t.TakerOrderID,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *StreamTrade) UnmarshalJSON(in []byte) error {
+	var raw rawStreamTrade
+	if err := json.Unmarshal(in, &raw); err != nil {
+		return err
+	}
+	*t = StreamTrade{
+		TID:          raw.TID,
+		Amount:       raw.Amount,
+		Price:        raw.Price,
+		Value:        raw.Value,
+		MakerSide:    streamSide(raw.MakerSide),
+		CreatedAt:    streamTime(raw.CreatedAt),
+		MakerOrderID: raw.MakerOrderID,
+		TakerOrderID: raw.TakerOrderID,
+	}
+	return nil
+}
+
+// Trade converts the stream trade into a Trade of the given book.
+func (t StreamTrade) Trade(book Book) Trade {
+	return Trade{
+		Book:      book,
+		CreatedAt: Time(t.CreatedAt),
+		Amount:    t.Amount,
+		MakerSide: t.MakerSide,
+		Price:     t.Price,
+		TID:       t.TID,
+	}
+}
+
+// StreamOrderDiff represents a change to a single order from the
+// "diff-orders" channel. Orders that are no longer open carry the status that
+// removed them from the book.
+type StreamOrderDiff struct {
+	OrderID   string
+	Side      OrderSide
+	Price     Monetary
+	Amount    Monetary
+	Value     Monetary
+	Status    OrderStatus
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type rawStreamOrderDiff struct {
+	OrderID   string    `json:"o"`
+	Side      streamInt `json:"t"`
+	Price     Monetary  `json:"r"`
+	Amount    Monetary  `json:"a,omitempty"`
+	Value     Monetary  `json:"v,omitempty"`
+	Status    string    `json:"s"`
+	CreatedAt streamInt `json:"d"`
+	UpdatedAt streamInt `json:"z,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (o StreamOrderDiff) MarshalJSON() ([]byte, error) {
+	return json.Marshal(rawStreamOrderDiff{
+		OrderID:   o.OrderID,
+		Side:      streamInt(streamSideCode(o.Side)),
+		Price:     o.Price,
+		Amount:    o.Amount,
+		Value:     o.Value,
+		Status:    streamStatusName(o.Status),
+		CreatedAt: streamInt(streamMillis(o.CreatedAt)),
+		UpdatedAt: streamInt(streamMillis(o.UpdatedAt)),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *StreamOrderDiff) UnmarshalJSON(in []byte) error {
+	var raw rawStreamOrderDiff
+	if err := json.Unmarshal(in, &raw); err != nil {
+		return err
+	}
+	*o = StreamOrderDiff{
+		OrderID:   raw.OrderID,
+		Side:      streamSide(raw.Side),
+		Price:     raw.Price,
+		Amount:    raw.Amount,
+		Value:     raw.Value,
+		Status:    streamStatus(raw.Status),
+		CreatedAt: streamTime(raw.CreatedAt),
+		UpdatedAt: streamTime(raw.UpdatedAt),
+	}
+	return nil
+}
+
+// Order converts the diff into an Order of the given book.
+func (o StreamOrderDiff) Order(book Book) Order {
+	return Order{
+		Book:   book,
+		Price:  o.Price,
+		Amount: o.Amount,
+		OID:    o.OrderID,
+	}
+}
+
+// StreamOrder represents an order of a snapshot from the "orders" channel.
+type StreamOrder struct {
+	OrderID   string
+	Side      OrderSide
+	Price     Monetary
+	Amount    Monetary
+	Value     Monetary
+	Status    OrderStatus
+	CreatedAt time.Time
+}
+
+type rawStreamOrder struct {
+	OrderID   string    `json:"o"`
+	Side      streamInt `json:"t"`
+	Price     Monetary  `json:"r"`
+	Amount    Monetary  `json:"a"`
+	Value     Monetary  `json:"v"`
+	Status    string    `json:"s"`
+	CreatedAt streamInt `json:"d"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (o StreamOrder) MarshalJSON() ([]byte, error) {
+	return json.Marshal(rawStreamOrder{
+		OrderID:   o.OrderID,
+		Side:      streamInt(streamSideCode(o.Side)),
+		Price:     o.Price,
+		Amount:    o.Amount,
+		Value:     o.Value,
+		Status:    streamStatusName(o.Status),
+		CreatedAt: streamInt(streamMillis(o.CreatedAt)),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *StreamOrder) UnmarshalJSON(in []byte) error {
+	var raw rawStreamOrder
+	if err := json.Unmarshal(in, &raw); err != nil {
+		return err
+	}
+	*o = StreamOrder{
+		OrderID:   raw.OrderID,
+		Side:      streamSide(raw.Side),
+		Price:     raw.Price,
+		Amount:    raw.Amount,
+		Value:     raw.Value,
+		Status:    streamStatus(raw.Status),
+		CreatedAt: streamTime(raw.CreatedAt),
+	}
+	return nil
+}
+
+// Order converts the stream order into an Order of the given book.
+func (o StreamOrder) Order(book Book) Order {
+	return Order{
+		Book:   book,
+		Price:  o.Price,
+		Amount: o.Amount,
+		OID:    o.OrderID,
+	}
+}
@@ -0,0 +1,198 @@
+package bitso
+
+import (
+	"errors"
+	"sync"
+)
+
+// defaultStreamBuffer is the capacity of the channels returned by the typed
+// subscription methods.
+const defaultStreamBuffer = 64
+
+var errUnknownStream = errors.New("unknown subscription")
+
+// stream delivers the messages of a single channel and book to a typed Go
+// channel.
+type stream struct {
+	channel string
+	book    Book
+
+	// key is the channel that was handed to the caller, used by Unsubscribe.
+	key interface{}
+
+	deliver func(msg interface{}, stop <-chan struct{}, done <-chan struct{})
+	closeCh func()
+
+	stop   chan struct{}
+	once   sync.Once
+	closed bool
+	mu     sync.Mutex
+}
+
+func newStream[T any](channel string, book Book, buffer int, policy OverflowPolicy, counters *overflowCounters) (*stream, chan T) {
+	ch := make(chan T, buffer)
+	s := &stream{
+		channel: channel,
+		book:    book,
+		key:     (<-chan T)(ch),
+		deliver: func(msg interface{}, stop <-chan struct{}, done <-chan struct{}) {
+			if v, ok := msg.(T); ok {
+				offer(ch, v, policy, counters, stop, done)
+			}
+		},
+		closeCh: func() {
+			close(ch)
+		},
+		stop: make(chan struct{}),
+	}
+	return s, ch
+}
+
+func (s *stream) matches(channel string, book Book) bool {
+	return s.channel == channel && s.book == book
+}
+
+// send blocks until msg is accepted by the consumer, the stream is closed or
+// done is closed.
+func (s *stream) send(msg interface{}, done <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.deliver(msg, s.stop, done)
+}
+
+func (s *stream) close() {
+	s.once.Do(func() {
+		// Unblock any pending send before taking the lock.
+		close(s.stop)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.closed = true
+		s.closeCh()
+	})
+}
+
+func subscribeStream[T any](ws *WebSocketConn, book *Book, channel string) (<-chan T, error) {
+	if book == nil {
+		return nil, errors.New("missing book")
+	}
+	buffer := defaultStreamBuffer
+	if ws.bufferSize > 0 {
+		buffer = ws.bufferSize
+	}
+	s, ch := newStream[T](channel, *book, buffer, ws.overflow, &ws.counters)
+	if err := ws.subscribe(book, channel, s); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// SubscribeTrades subscribes to the "trades" channel of the given book and
+// returns a channel that receives only those messages.
+func (ws *WebSocketConn) SubscribeTrades(book *Book) (<-chan WebSocketTrade, error) {
+	return subscribeStream[WebSocketTrade](ws, book, "trades")
+}
+
+// SubscribeDiffOrders is like SubscribeTrades but for the "diff-orders"
+// channel.
+func (ws *WebSocketConn) SubscribeDiffOrders(book *Book) (<-chan WebSocketDiffOrder, error) {
+	return subscribeStream[WebSocketDiffOrder](ws, book, "diff-orders")
+}
+
+// SubscribeOrders is like SubscribeTrades but for the "orders" channel.
+func (ws *WebSocketConn) SubscribeOrders(book *Book) (<-chan WebSocketOrder, error) {
+	return subscribeStream[WebSocketOrder](ws, book, "orders")
+}
+
+// Unsubscribe stops delivering messages to a channel returned by one of the
+// typed subscription methods and closes it.
+//
+// Bitso does not support unsubscribing, so the connection keeps receiving
+// the messages of the channel; they are just not delivered anymore.
+func (ws *WebSocketConn) Unsubscribe(ch interface{}) error {
+	ws.mu.Lock()
+	i := -1
+	for j, s := range ws.streams {
+		if s.key == ch {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		ws.mu.Unlock()
+		return errUnknownStream
+	}
+	s := ws.streams[i]
+	ws.streams = append(ws.streams[:i:i], ws.streams[i+1:]...)
+	ws.mu.Unlock()
+
+	s.close()
+	return nil
+}
+
+// messageChannel returns the channel and book a decoded message belongs to.
+func messageChannel(msg interface{}) (string, Book, bool) {
+	switch m := msg.(type) {
+	case WebSocketTrade:
+		return "trades", m.Book, true
+	case WebSocketDiffOrder:
+		return "diff-orders", m.Book, true
+	case WebSocketOrder:
+		return "orders", m.Book, true
+	}
+	return "", Book{}, false
+}
+
+// dispatch hands msg to all the streams that are interested in it and to the
+// channel returned by Receive if the message was requested with Subscribe.
+func (ws *WebSocketConn) dispatch(msg interface{}) error {
+	var (
+		targets []*stream
+		inbox   bool
+	)
+
+	ws.mu.Lock()
+	if channel, book, ok := messageChannel(msg); ok {
+		for _, s := range ws.streams {
+			if s.matches(channel, book) {
+				targets = append(targets, s)
+			}
+		}
+		for _, m := range ws.inboxChannels {
+			if m.Type == channel && (m.Book == nil || *m.Book == book) {
+				inbox = true
+				break
+			}
+		}
+	} else {
+		// Replies and unknown messages only go to the Receive channel, and
+		// only if someone is using it.
+		inbox = len(ws.inboxChannels) > 0
+	}
+	ws.mu.Unlock()
+
+	for _, s := range targets {
+		s.send(msg, ws.done)
+	}
+
+	if inbox && !offer(ws.inbox, msg, ws.overflow, &ws.counters, nil, ws.done) {
+		return ErrWebSocketClosed
+	}
+	return nil
+}
+
+func (ws *WebSocketConn) closeStreams() {
+	ws.mu.Lock()
+	streams := ws.streams
+	ws.streams = nil
+	ws.mu.Unlock()
+
+	for _, s := range streams {
+		s.close()
+	}
+}
@@ -1,14 +1,123 @@
 package bitso
 
 import (
+	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// testWebSocketServer accepts websocket connections and records the messages
+// sent by clients.
+type testWebSocketServer struct {
+	*httptest.Server
+
+	conns    chan *websocket.Conn
+	headers  chan http.Header
+	received chan WebSocketMessage
+
+	// ignorePings stops the server from answering pings.
+	ignorePings atomic.Bool
+	// pongs counts the pings answered.
+	pongs atomic.Int32
+}
+
+func newTestWebSocketServer(t *testing.T) *testWebSocketServer {
+	t.Helper()
+
+	s := &testWebSocketServer{
+		conns:    make(chan *websocket.Conn, 8),
+		headers:  make(chan http.Header, 8),
+		received: make(chan WebSocketMessage, 32),
+	}
+	upgrader := websocket.Upgrader{}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.SetPingHandler(func(data string) error {
+			if s.ignorePings.Load() {
+				return nil
+			}
+			s.pongs.Add(1)
+			return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		})
+		s.conns <- conn
+		s.headers <- r.Header
+
+		for {
+			var m WebSocketMessage
+			if err := conn.ReadJSON(&m); err != nil {
+				return
+			}
+			s.received <- m
+		}
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *testWebSocketServer) endpoint() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+func (s *testWebSocketServer) accept(t *testing.T) *websocket.Conn {
+	t.Helper()
+
+	select {
+	case conn := <-s.conns:
+		return conn
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for connection")
+	}
+	return nil
+}
+
+func (s *testWebSocketServer) expectMessage(t *testing.T) WebSocketMessage {
+	t.Helper()
+
+	select {
+	case m := <-s.received:
+		return m
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+	return WebSocketMessage{}
+}
+
+func testReconnectPolicy(maxAttempts int) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: maxAttempts,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  5 * time.Millisecond,
+	}
+}
+
+func expectEvent(t *testing.T, ws *WebSocketConn, expected WebSocketEventType) WebSocketEvent {
+	t.Helper()
+
+	select {
+	case event := <-ws.Events():
+		require.Equal(t, expected, event.Type)
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timeout waiting for %v event", expected)
+	}
+	return WebSocketEvent{}
+}
+
 func TestWebSocketReply_UnmarshalJSON(t *testing.T) {
 	t.Run("subscribe response", func(t *testing.T) {
 		jsonData := `{
@@ -83,18 +192,18 @@ func TestWebSocketTrade_UnmarshalJSON(t *testing.T) {
 	require.Len(t, trade.Payload, 2)
 
 	// First trade
-	assert.Equal(t, uint64(12345), trade.Payload[0].TID)
+	assert.Equal(t, TID(12345), trade.Payload[0].TID)
 	assert.Equal(t, "0.5", string(trade.Payload[0].Amount))
 	assert.Equal(t, "500000.00", string(trade.Payload[0].Price))
 	assert.Equal(t, "250000.00", string(trade.Payload[0].Value))
-	assert.Equal(t, "0", trade.Payload[0].MakerSide)
-	assert.Equal(t, uint64(1705312200000), trade.Payload[0].CreationTimestamp)
+	assert.Equal(t, OrderSideBuy, trade.Payload[0].MakerSide)
+	assert.Equal(t, time.UnixMilli(1705312200000), trade.Payload[0].CreatedAt)
 	assert.Equal(t, "maker-order-123", trade.Payload[0].MakerOrderID)
 	assert.Equal(t, "taker-order-456", trade.Payload[0].TakerOrderID)
 
 	// Second trade
-	assert.Equal(t, uint64(12346), trade.Payload[1].TID)
-	assert.Equal(t, "1", trade.Payload[1].MakerSide)
+	assert.Equal(t, TID(12346), trade.Payload[1].TID)
+	assert.Equal(t, OrderSideSell, trade.Payload[1].MakerSide)
 }
 
 func TestWebSocketDiffOrder_UnmarshalJSON(t *testing.T) {
@@ -131,21 +240,22 @@ func TestWebSocketDiffOrder_UnmarshalJSON(t *testing.T) {
 
 	require.NoError(t, err)
 	assert.Equal(t, "eth_mxn", diff.Book.String())
+	assert.Equal(t, uint64(12345), diff.Sequence)
 	require.Len(t, diff.Payload, 2)
 
 	// First order
-	assert.Equal(t, uint64(1705312200000), diff.Payload[0].Timestamp)
+	assert.Equal(t, time.UnixMilli(1705312200000), diff.Payload[0].CreatedAt)
 	assert.Equal(t, "35000.00", string(diff.Payload[0].Price))
-	assert.Equal(t, "open", diff.Payload[0].Status)
-	assert.Equal(t, 0, diff.Payload[0].Position)
+	assert.Equal(t, OrderStatusOpen, diff.Payload[0].Status)
+	assert.Equal(t, OrderSideBuy, diff.Payload[0].Side)
 	assert.Equal(t, "2.5", string(diff.Payload[0].Amount))
 	assert.Equal(t, "87500.00", string(diff.Payload[0].Value))
-	assert.Equal(t, uint64(1705312200500), diff.Payload[0].LastUpdateTimestamp)
+	assert.Equal(t, time.UnixMilli(1705312200500), diff.Payload[0].UpdatedAt)
 	assert.Equal(t, "order-123", diff.Payload[0].OrderID)
 
 	// Second order (cancelled)
-	assert.Equal(t, "cancelled", diff.Payload[1].Status)
-	assert.Equal(t, 1, diff.Payload[1].Position)
+	assert.Equal(t, OrderStatusCancelled, diff.Payload[1].Status)
+	assert.Equal(t, OrderSideSell, diff.Payload[1].Side)
 }
 
 func TestWebSocketOrder_UnmarshalJSON(t *testing.T) {
@@ -197,17 +307,17 @@ func TestWebSocketOrder_UnmarshalJSON(t *testing.T) {
 	require.Len(t, order.Payload.Bids, 2)
 	assert.Equal(t, "0.5", string(order.Payload.Bids[0].Amount))
 	assert.Equal(t, "bid-order-1", order.Payload.Bids[0].OrderID)
-	assert.Equal(t, 0, order.Payload.Bids[0].Position)
+	assert.Equal(t, OrderSideBuy, order.Payload.Bids[0].Side)
 	assert.Equal(t, "499000.00", string(order.Payload.Bids[0].Price))
-	assert.Equal(t, "open", order.Payload.Bids[0].Status)
-	assert.Equal(t, uint64(1705312200000), order.Payload.Bids[0].Timestamp)
+	assert.Equal(t, OrderStatusOpen, order.Payload.Bids[0].Status)
+	assert.Equal(t, time.UnixMilli(1705312200000), order.Payload.Bids[0].CreatedAt)
 	assert.Equal(t, "249500.00", string(order.Payload.Bids[0].Value))
 
 	// Asks
 	require.Len(t, order.Payload.Asks, 1)
 	assert.Equal(t, "0.3", string(order.Payload.Asks[0].Amount))
 	assert.Equal(t, "ask-order-1", order.Payload.Asks[0].OrderID)
-	assert.Equal(t, 1, order.Payload.Asks[0].Position)
+	assert.Equal(t, OrderSideSell, order.Payload.Asks[0].Side)
 }
 
 func TestWebSocketMessage_MarshalJSON(t *testing.T) {
@@ -389,16 +499,21 @@ func TestWebSocketDiffOrder_StatusValues(t *testing.T) {
 			err := json.Unmarshal([]byte(jsonData), &diff)
 
 			require.NoError(t, err)
-			assert.Equal(t, status, diff.Payload[0].Status)
+			assert.Equal(t, status, diff.Payload[0].Status.String())
 		})
 	}
 }
 
 func TestWebSocketTrade_MakerSideValues(t *testing.T) {
 	// Test maker side values (0=buy, 1=sell per API docs)
-	makerSides := []string{"0", "1"}
+	makerSides := map[string]OrderSide{
+		`"0"`: OrderSideBuy,
+		`"1"`: OrderSideSell,
+		`0`:   OrderSideBuy,
+		`1`:   OrderSideSell,
+	}
 
-	for _, side := range makerSides {
+	for side, expected := range makerSides {
 		t.Run("side_"+side, func(t *testing.T) {
 			jsonData := `{
 				"type": "trades",
@@ -409,7 +524,7 @@ func TestWebSocketTrade_MakerSideValues(t *testing.T) {
 						"a": "1.0",
 						"r": "500000.00",
 						"v": "500000.00",
-						"t": "` + side + `",
+						"t": ` + side + `,
 						"x": 1705312200000,
 						"mo": "maker-1",
 						"to": "taker-1"
@@ -421,7 +536,670 @@ func TestWebSocketTrade_MakerSideValues(t *testing.T) {
 			err := json.Unmarshal([]byte(jsonData), &trade)
 
 			require.NoError(t, err)
-			assert.Equal(t, side, trade.Payload[0].MakerSide)
+			assert.Equal(t, expected, trade.Payload[0].MakerSide)
 		})
 	}
 }
+
+func TestWebSocketConn_FrameHandler(t *testing.T) {
+	server := newTestWebSocketServer(t)
+
+	frames := make(chan string, 2)
+	ws, err := NewWebSocketConn(
+		WithWebSocketURL(server.endpoint()),
+		WithReconnectPolicy(nil),
+		WithFrameHandler(func(receivedAt time.Time, frame []byte) {
+			assert.False(t, receivedAt.IsZero())
+			frames <- string(frame)
+		}),
+	)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	conn := server.accept(t)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type": "ka"}`)))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`not json`)))
+
+	for _, expected := range []string{`{"type": "ka"}`, `not json`} {
+		select {
+		case frame := <-frames:
+			assert.Equal(t, expected, frame)
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for frame")
+		}
+	}
+}
+
+func TestWebSocketConn_EndToEnd(t *testing.T) {
+	server := newTestWebSocketServer(t)
+
+	ws, err := NewWebSocketConn(WithWebSocketURL(server.endpoint()), WithReconnectPolicy(testReconnectPolicy(3)))
+	require.NoError(t, err)
+	defer ws.Close()
+
+	conn := server.accept(t)
+	expectEvent(t, ws, WebSocketEventConnected)
+
+	require.NoError(t, ws.Subscribe(NewBook(BTC, MXN), "trades"))
+	m := server.expectMessage(t)
+	assert.Equal(t, "subscribe", m.Action)
+	assert.Equal(t, "trades", m.Type)
+	assert.Equal(t, "btc_mxn", m.Book.String())
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type": "ka"}`)))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(
+		`{"type": "trades", "book": "btc_mxn", "payload": [{"i": 1, "a": "0.5", "r": "500000.00", "v": "250000.00", "t": "0"}]}`,
+	)))
+
+	select {
+	case msg := <-ws.Receive():
+		trade, ok := msg.(WebSocketTrade)
+		require.True(t, ok, "expecting a trade, got %#v", msg)
+		require.Len(t, trade.Payload, 1)
+		assert.Equal(t, TID(1), trade.Payload[0].TID)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for trade")
+	}
+}
+
+func TestWebSocketConn_Reconnect(t *testing.T) {
+	server := newTestWebSocketServer(t)
+
+	ws, err := NewWebSocketConn(WithWebSocketURL(server.endpoint()), WithReconnectPolicy(testReconnectPolicy(5)))
+	require.NoError(t, err)
+	defer ws.Close()
+
+	conn := server.accept(t)
+	expectEvent(t, ws, WebSocketEventConnected)
+
+	require.NoError(t, ws.Subscribe(NewBook(BTC, MXN), "trades"))
+	require.NoError(t, ws.Subscribe(NewBook(ETH, MXN), "diff-orders"))
+	require.NoError(t, ws.Subscribe(NewBook(BTC, MXN), "trades"))
+	server.expectMessage(t)
+	server.expectMessage(t)
+	server.expectMessage(t)
+
+	// Drop the connection from the server side.
+	conn.Close()
+
+	expectEvent(t, ws, WebSocketEventReconnecting)
+	conn = server.accept(t)
+	expectEvent(t, ws, WebSocketEventConnected)
+
+	// Subscriptions are replayed once each.
+	first, second := server.expectMessage(t), server.expectMessage(t)
+	assert.Equal(t, "trades", first.Type)
+	assert.Equal(t, "diff-orders", second.Type)
+	assert.Equal(t, "eth_mxn", second.Book.String())
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(
+		`{"action": "subscribe", "response": "ok", "type": "trades"}`,
+	)))
+
+	select {
+	case msg := <-ws.Receive():
+		reply, ok := msg.(WebSocketReply)
+		require.True(t, ok)
+		assert.Equal(t, "subscribe", reply.Action)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for reply")
+	}
+}
+
+func TestWebSocketConn_GiveUp(t *testing.T) {
+	server := newTestWebSocketServer(t)
+
+	ws, err := NewWebSocketConn(WithWebSocketURL(server.endpoint()), WithReconnectPolicy(testReconnectPolicy(2)))
+	require.NoError(t, err)
+	defer ws.Close()
+
+	conn := server.accept(t)
+	expectEvent(t, ws, WebSocketEventConnected)
+
+	server.Close()
+	conn.Close()
+
+	expectEvent(t, ws, WebSocketEventReconnecting)
+	event := expectEvent(t, ws, WebSocketEventClosed)
+	assert.Error(t, event.Err)
+
+	select {
+	case _, ok := <-ws.Receive():
+		assert.False(t, ok, "receive channel must be closed")
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the receive channel to be closed")
+	}
+
+	assert.ErrorIs(t, ws.Subscribe(NewBook(BTC, MXN), "trades"), ErrWebSocketClosed)
+}
+
+func TestWebSocketConn_Close(t *testing.T) {
+	server := newTestWebSocketServer(t)
+
+	ws, err := NewWebSocketConn(WithWebSocketURL(server.endpoint()), WithReconnectPolicy(testReconnectPolicy(3)))
+	require.NoError(t, err)
+
+	server.accept(t)
+	expectEvent(t, ws, WebSocketEventConnected)
+
+	require.NoError(t, ws.Close())
+	require.NoError(t, ws.Close())
+
+	event := expectEvent(t, ws, WebSocketEventClosed)
+	assert.NoError(t, event.Err)
+
+	select {
+	case _, ok := <-ws.Receive():
+		assert.False(t, ok, "receive channel must be closed")
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the receive channel to be closed")
+	}
+}
+
+func TestWebSocketEventType_String(t *testing.T) {
+	assert.Equal(t, "connected", WebSocketEventConnected.String())
+	assert.Equal(t, "reconnecting", WebSocketEventReconnecting.String())
+	assert.Equal(t, "closed", WebSocketEventClosed.String())
+	assert.Equal(t, "sequence-gap", WebSocketEventSequenceGap.String())
+	assert.Equal(t, "WebSocketEventType(0)", WebSocketEventNone.String())
+}
+
+func TestWebSocketConn_TypedSubscriptions(t *testing.T) {
+	server := newTestWebSocketServer(t)
+
+	ws, err := NewWebSocketConn(WithWebSocketURL(server.endpoint()), WithReconnectPolicy(testReconnectPolicy(3)))
+	require.NoError(t, err)
+	defer ws.Close()
+
+	conn := server.accept(t)
+
+	btcTrades, err := ws.SubscribeTrades(NewBook(BTC, MXN))
+	require.NoError(t, err)
+	ethTrades, err := ws.SubscribeTrades(NewBook(ETH, MXN))
+	require.NoError(t, err)
+	diffs, err := ws.SubscribeDiffOrders(NewBook(BTC, MXN))
+	require.NoError(t, err)
+
+	for _, expected := range []string{"trades", "trades", "diff-orders"} {
+		m := server.expectMessage(t)
+		assert.Equal(t, "subscribe", m.Action)
+		assert.Equal(t, expected, m.Type)
+	}
+
+	frames := []string{
+		`{"action": "subscribe", "response": "ok", "type": "trades"}`,
+		`{"type": "trades", "book": "eth_mxn", "payload": [{"i": 7, "a": "1", "r": "50000", "v": "50000", "t": "1"}]}`,
+		`{"type": "diff-orders", "book": "btc_mxn", "sequence": 12, "payload": [{"o": "x", "t": 0, "s": "open", "r": "1", "a": "1"}]}`,
+		`{"type": "trades", "book": "btc_mxn", "payload": [{"i": 9, "a": "1", "r": "500000", "v": "500000", "t": "0"}]}`,
+	}
+	for _, frame := range frames {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(frame)))
+	}
+
+	select {
+	case trade := <-btcTrades:
+		require.Len(t, trade.Payload, 1)
+		assert.Equal(t, TID(9), trade.Payload[0].TID)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for btc_mxn trade")
+	}
+
+	select {
+	case trade := <-ethTrades:
+		require.Len(t, trade.Payload, 1)
+		assert.Equal(t, TID(7), trade.Payload[0].TID)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for eth_mxn trade")
+	}
+
+	select {
+	case diff := <-diffs:
+		assert.Equal(t, uint64(12), diff.Sequence)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for diff order")
+	}
+
+	// Nothing was requested with Subscribe.
+	select {
+	case msg := <-ws.Receive():
+		t.Fatalf("unexpected message %#v", msg)
+	default:
+	}
+}
+
+func TestWebSocketConn_Unsubscribe(t *testing.T) {
+	server := newTestWebSocketServer(t)
+
+	ws, err := NewWebSocketConn(WithWebSocketURL(server.endpoint()), WithReconnectPolicy(testReconnectPolicy(3)))
+	require.NoError(t, err)
+
+	server.accept(t)
+
+	trades, err := ws.SubscribeTrades(NewBook(BTC, MXN))
+	require.NoError(t, err)
+	orders, err := ws.SubscribeOrders(NewBook(BTC, MXN))
+	require.NoError(t, err)
+
+	require.NoError(t, ws.Unsubscribe(trades))
+	_, ok := <-trades
+	assert.False(t, ok, "unsubscribed channel must be closed")
+
+	assert.Error(t, ws.Unsubscribe(trades))
+	assert.Error(t, ws.Unsubscribe(make(chan WebSocketTrade)))
+
+	_, err = ws.SubscribeOrders(nil)
+	assert.Error(t, err)
+
+	require.NoError(t, ws.Close())
+
+	select {
+	case _, ok := <-orders:
+		assert.False(t, ok, "channel must be closed after closing the connection")
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the orders channel to be closed")
+	}
+
+	_, err = ws.SubscribeTrades(NewBook(BTC, MXN))
+	assert.ErrorIs(t, err, ErrWebSocketClosed)
+}
+
+func TestWebSocketConn_Options(t *testing.T) {
+	server := newTestWebSocketServer(t)
+
+	header := http.Header{}
+	header.Set("X-Test", "bitso-go")
+
+	dialer := &websocket.Dialer{HandshakeTimeout: time.Second}
+
+	ws, err := NewWebSocketConn(
+		WithWebSocketURL(server.endpoint()),
+		WithWebSocketDialer(dialer),
+		WithWebSocketHeader(header),
+		WithReconnectPolicy(nil),
+	)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	assert.Equal(t, server.endpoint(), ws.URL())
+
+	conn := server.accept(t)
+	select {
+	case h := <-server.headers:
+		assert.Equal(t, "bitso-go", h.Get("X-Test"))
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for headers")
+	}
+	expectEvent(t, ws, WebSocketEventConnected)
+
+	// Reconnection is disabled, losing the connection closes it for good.
+	conn.Close()
+	event := expectEvent(t, ws, WebSocketEventReconnecting)
+	assert.Error(t, event.Err)
+	expectEvent(t, ws, WebSocketEventClosed)
+}
+
+func TestNewWebSocketConnContext(t *testing.T) {
+	server := newTestWebSocketServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewWebSocketConnContext(ctx, WithWebSocketURL(server.endpoint()))
+	assert.ErrorIs(t, err, context.Canceled)
+
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+
+	_, err = NewWebSocketConn(WithWebSocketURL("ws" + strings.TrimPrefix(notFound.URL, "http")))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "404")
+}
+
+func TestDecodeWebSocketMessage(t *testing.T) {
+	msg, err := DecodeWebSocketMessage([]byte(`{"type": "ka"}`))
+	require.NoError(t, err)
+	assert.Equal(t, WebSocketReply{Type: "ka"}, msg)
+
+	msg, err = DecodeWebSocketMessage([]byte(`{"action": "subscribe", "response": "ok", "time": 1, "type": "orders"}`))
+	require.NoError(t, err)
+	assert.IsType(t, WebSocketReply{}, msg)
+
+	msg, err = DecodeWebSocketMessage([]byte(`{"type": "orders", "book": "btc_mxn", "sequence": 8, "payload": {"bids": [], "asks": []}}`))
+	require.NoError(t, err)
+	require.IsType(t, WebSocketOrder{}, msg)
+	assert.Equal(t, uint64(8), msg.(WebSocketOrder).Sequence)
+
+	msg, err = DecodeWebSocketMessage([]byte(`{"type": "diff-orders", "book": "btc_mxn", "sequence": 3, "payload": []}`))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), msg.(WebSocketDiffOrder).Sequence)
+
+	for _, frame := range []string{
+		`{"type": "trades"`,
+		`{"type": "trades", "book": "btc_mxn", "payload": {"i": 1}}`,
+	} {
+		_, err := DecodeWebSocketMessage([]byte(frame))
+		require.Error(t, err, frame)
+
+		var decodeErr *WebSocketDecodeError
+		require.ErrorAs(t, err, &decodeErr)
+		assert.Equal(t, frame, string(decodeErr.Frame))
+	}
+}
+
+func TestWebSocketConn_Errors(t *testing.T) {
+	server := newTestWebSocketServer(t)
+
+	ws, err := NewWebSocketConn(
+		WithWebSocketURL(server.endpoint()),
+		WithReconnectPolicy(testReconnectPolicy(3)),
+		WithWebSocketLogger(zerolog.Nop()),
+	)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	ws.SetLogLevel(LogLevelError)
+
+	conn := server.accept(t)
+	expectEvent(t, ws, WebSocketEventConnected)
+
+	trades, err := ws.SubscribeTrades(NewBook(BTC, MXN))
+	require.NoError(t, err)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`not json`)))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(
+		`{"type": "trades", "book": "btc_mxn", "payload": [{"i": 1, "a": "1", "r": "1", "v": "1", "t": "0"}]}`,
+	)))
+
+	select {
+	case err := <-ws.Errors():
+		var decodeErr *WebSocketDecodeError
+		require.ErrorAs(t, err, &decodeErr)
+		assert.Equal(t, "not json", string(decodeErr.Frame))
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for error")
+	}
+
+	// The connection survives malformed messages.
+	select {
+	case trade := <-trades:
+		assert.Equal(t, TID(1), trade.Payload[0].TID)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for trade")
+	}
+
+	select {
+	case event := <-ws.Events():
+		t.Fatalf("unexpected event %v", event.Type)
+	default:
+	}
+}
+
+func TestWebSocketConn_IdleTimeout(t *testing.T) {
+	server := newTestWebSocketServer(t)
+	server.ignorePings.Store(true)
+
+	ws, err := NewWebSocketConn(
+		WithWebSocketURL(server.endpoint()),
+		WithReconnectPolicy(testReconnectPolicy(3)),
+		WithIdleTimeout(200*time.Millisecond),
+		WithWebSocketLogger(zerolog.Nop()),
+	)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	assert.True(t, ws.LastMessageAt().IsZero())
+
+	conn := server.accept(t)
+	expectEvent(t, ws, WebSocketEventConnected)
+
+	start := time.Now()
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type": "ka"}`)))
+	require.Eventually(t, func() bool {
+		return !ws.LastMessageAt().Before(start)
+	}, time.Second, time.Millisecond)
+
+	// The server goes silent.
+	event := expectEvent(t, ws, WebSocketEventReconnecting)
+	assert.ErrorIs(t, event.Err, ErrWebSocketIdle)
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	server.accept(t)
+	expectEvent(t, ws, WebSocketEventConnected)
+}
+
+func TestWebSocketConn_IdleTimeout_Pongs(t *testing.T) {
+	server := newTestWebSocketServer(t)
+
+	ws, err := NewWebSocketConn(
+		WithWebSocketURL(server.endpoint()),
+		WithReconnectPolicy(nil),
+		WithIdleTimeout(200*time.Millisecond),
+		WithWebSocketLogger(zerolog.Nop()),
+	)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	server.accept(t)
+	expectEvent(t, ws, WebSocketEventConnected)
+
+	// The server answers pings but sends no frames.
+	start := time.Now()
+	event := expectEvent(t, ws, WebSocketEventReconnecting)
+	assert.ErrorIs(t, event.Err, ErrWebSocketIdle)
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	assert.Positive(t, server.pongs.Load())
+	assert.True(t, ws.LastMessageAt().IsZero())
+}
+
+func TestWebSocketConn_IdleTimeout_SlowConsumer(t *testing.T) {
+	server := newTestWebSocketServer(t)
+	server.ignorePings.Store(true)
+
+	ws, err := NewWebSocketConn(
+		WithWebSocketURL(server.endpoint()),
+		WithReconnectPolicy(nil),
+		WithIdleTimeout(200*time.Millisecond),
+		WithBufferSize(1),
+		WithWebSocketLogger(zerolog.Nop()),
+	)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	conn := server.accept(t)
+	expectEvent(t, ws, WebSocketEventConnected)
+
+	trades, err := ws.SubscribeTrades(NewBook(BTC, MXN))
+	require.NoError(t, err)
+	server.expectMessage(t)
+
+	trade := []byte(`{"type": "trades", "book": "btc_mxn", "payload": [{"i": 1, "a": "0.1", "r": "500000", "v": "50000", "t": 0}]}`)
+	receive := func(i int) {
+		t.Helper()
+		select {
+		case <-trades:
+		case <-time.After(100 * time.Millisecond):
+			t.Fatalf("message %d not received", i)
+		}
+	}
+
+	// The second message blocks delivery for longer than the idle timeout.
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, trade))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, trade))
+	time.Sleep(500 * time.Millisecond)
+	receive(0)
+	receive(1)
+
+	// Waiting for the next message starts after delivery.
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, trade))
+	receive(2)
+
+	select {
+	case event := <-ws.Events():
+		t.Fatalf("unexpected event %v: %v", event.Type, event.Err)
+	default:
+	}
+}
+
+func TestWebSocketConn_SequenceGaps(t *testing.T) {
+	ws := &WebSocketConn{
+		events: make(chan WebSocketEvent, 16),
+		logger: zerolog.Nop(),
+	}
+
+	btc, eth := *NewBook(BTC, MXN), *NewBook(ETH, MXN)
+
+	diff := func(book Book, sequence uint64) WebSocketDiffOrder {
+		return WebSocketDiffOrder{Book: book, Sequence: sequence}
+	}
+	snapshot := func(book Book, sequence uint64) WebSocketOrder {
+		return WebSocketOrder{Book: book, Sequence: sequence}
+	}
+
+	for _, msg := range []interface{}{
+		diff(btc, 10),
+		diff(btc, 11),
+		diff(eth, 50),
+		diff(eth, 51),
+		snapshot(btc, 3),
+		snapshot(btc, 7),
+		WebSocketReply{Type: "trades"},
+	} {
+		ws.checkSequence(msg)
+	}
+
+	select {
+	case event := <-ws.Events():
+		t.Fatalf("unexpected event %#v", event)
+	default:
+	}
+
+	ws.checkSequence(diff(btc, 13))
+	event := expectEvent(t, ws, WebSocketEventSequenceGap)
+	assert.Equal(t, "diff-orders", event.Channel)
+	assert.Equal(t, btc, event.Book)
+	assert.Equal(t, uint64(12), event.Expected)
+	assert.Equal(t, uint64(13), event.Received)
+
+	// Out of order.
+	ws.checkSequence(diff(btc, 12))
+	event = expectEvent(t, ws, WebSocketEventSequenceGap)
+	assert.Equal(t, uint64(14), event.Expected)
+	assert.Equal(t, uint64(12), event.Received)
+
+	ws.checkSequence(diff(btc, 14))
+	ws.checkSequence(diff(eth, 52))
+
+	ws.checkSequence(snapshot(btc, 6))
+	event = expectEvent(t, ws, WebSocketEventSequenceGap)
+	assert.Equal(t, "orders", event.Channel)
+	assert.Equal(t, uint64(6), event.Received)
+
+	select {
+	case event := <-ws.Events():
+		t.Fatalf("unexpected event %#v", event)
+	default:
+	}
+}
+
+func TestStreamPayload_RoundTrip(t *testing.T) {
+	createdAt := time.UnixMilli(1705312200000)
+
+	t.Run("trade", func(t *testing.T) {
+		trade := StreamTrade{
+			TID:          5,
+			Amount:       "0.5",
+			Price:        "500000.00",
+			Value:        "250000.00",
+			MakerSide:    OrderSideSell,
+			CreatedAt:    createdAt,
+			MakerOrderID: "m",
+			TakerOrderID: "t",
+		}
+
+		data, err := json.Marshal(trade)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"i": 5, "a": "0.5", "r": "500000.00", "v": "250000.00", "t": 1, "x": 1705312200000, "mo": "m", "to": "t"}`, string(data))
+
+		var decoded StreamTrade
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, trade, decoded)
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		diff := StreamOrderDiff{
+			OrderID:   "o",
+			Side:      OrderSideBuy,
+			Price:     "1.00",
+			Status:    OrderStatusCancelled,
+			CreatedAt: createdAt,
+		}
+
+		data, err := json.Marshal(diff)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"o": "o", "t": 0, "r": "1.00", "s": "cancelled", "d": 1705312200000}`, string(data))
+
+		var decoded StreamOrderDiff
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, diff, decoded)
+	})
+
+	t.Run("order", func(t *testing.T) {
+		order := StreamOrder{
+			OrderID:   "o",
+			Side:      OrderSideSell,
+			Price:     "2.00",
+			Amount:    "3",
+			Value:     "6.00",
+			Status:    OrderStatusOpen,
+			CreatedAt: createdAt,
+		}
+
+		data, err := json.Marshal(order)
+		require.NoError(t, err)
+
+		var decoded StreamOrder
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, order, decoded)
+	})
+
+	t.Run("unknown status", func(t *testing.T) {
+		var order StreamOrder
+		require.NoError(t, json.Unmarshal([]byte(`{"o": "o", "t": 1, "s": "undefined"}`), &order))
+		assert.Equal(t, OrderStatusNone, order.Status)
+		assert.True(t, order.CreatedAt.IsZero())
+	})
+}
+
+func TestStreamPayload_Conversions(t *testing.T) {
+	book := *NewBook(BTC, MXN)
+	createdAt := time.UnixMilli(1705312200000)
+
+	msg := WebSocketTrade{
+		Book: book,
+		Payload: []StreamTrade{
+			{TID: 1, Amount: "0.5", Price: "500000", MakerSide: OrderSideBuy, CreatedAt: createdAt},
+		},
+	}
+	assert.Equal(t, []Trade{{
+		Book:      book,
+		CreatedAt: Time(createdAt),
+		Amount:    "0.5",
+		MakerSide: OrderSideBuy,
+		Price:     "500000",
+		TID:       1,
+	}}, msg.Trades())
+
+	diff := StreamOrderDiff{OrderID: "a", Price: "1", Amount: "2"}
+	assert.Equal(t, Order{Book: book, Price: "1", Amount: "2", OID: "a"}, diff.Order(book))
+
+	var snapshot WebSocketOrder
+	snapshot.Book = book
+	snapshot.Sequence = 42
+	snapshot.Payload.Bids = []StreamOrder{{OrderID: "b", Side: OrderSideBuy, Price: "1", Amount: "1"}}
+	snapshot.Payload.Asks = []StreamOrder{{OrderID: "c", Side: OrderSideSell, Price: "2", Amount: "1"}}
+
+	orderBook := snapshot.OrderBook()
+	assert.Equal(t, "42", orderBook.Sequence)
+	assert.Equal(t, []Order{{Book: book, Price: "1", Amount: "1", OID: "b"}}, orderBook.Bids)
+	assert.Equal(t, []Order{{Book: book, Price: "2", Amount: "1", OID: "c"}}, orderBook.Asks)
+}